@@ -0,0 +1,323 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusCorrupted
+	StatusIncomplete
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusCorrupted:
+		return "corrupted"
+	case StatusIncomplete:
+		return "incomplete"
+	}
+	return "unknown"
+}
+
+type Result struct {
+	Status Status
+	// byte offset and 1-based rune column of the first error, for incomplete
+	// lines that is the end of the line, both are -1 for ok lines
+	Offset int
+	Column int
+	// the closer that would have been valid at the error, empty when nothing was open
+	Expected string
+	// the closer found at the error, empty for incomplete lines
+	Found string
+	// the closers that complete an incomplete line, innermost first
+	Completion string
+	// a balanced version of a corrupted or incomplete line, see Checker.Fix
+	Repair string
+
+	// delimiter indices behind Found and Completion, for scoring
+	found   int
+	missing []uint8
+}
+
+type tokenKind int
+
+const (
+	tokenOpen tokenKind = iota
+	tokenClose
+	tokenEscape
+)
+
+type token struct {
+	text      string
+	kind      tokenKind
+	delimiter int
+	// whether the token starts or ends with a letter, digit or '_' and needs a word boundary there
+	wordStart bool
+	wordEnd   bool
+}
+
+func newToken(text string, kind tokenKind, delimiter int) token {
+	first, _ := utf8.DecodeRuneInString(text)
+	last, _ := utf8.DecodeLastRuneInString(text)
+	return token{text, kind, delimiter, isWordRune(first), isWordRune(last)}
+}
+
+type Checker struct {
+	language *Language
+	// the tokens by their first byte, longest first so "begin" wins over "b" when
+	// both would match, anything else can be skipped without matching
+	byFirst     [256][]token
+	maxTokenLen int
+}
+
+func NewChecker() *Checker {
+	return NewLanguageChecker(PuzzleLanguage())
+}
+
+func NewLanguageChecker(language *Language) *Checker {
+	tokens := []token{}
+
+	for i, d := range language.Delimiters {
+		tokens = append(tokens, newToken(d.Open, tokenOpen, i))
+		if d.Close != d.Open {
+			tokens = append(tokens, newToken(d.Close, tokenClose, i))
+		}
+		if d.Escape != "" {
+			tokens = append(tokens, newToken(d.Escape, tokenEscape, i))
+		}
+	}
+
+	for i := 1; i < len(tokens); i++ {
+		for j := i; j > 0 && len(tokens[j].text) > len(tokens[j-1].text); j-- {
+			tokens[j], tokens[j-1] = tokens[j-1], tokens[j]
+		}
+	}
+
+	checker := &Checker{language: language}
+
+	for _, t := range tokens {
+		checker.byFirst[t.text[0]] = append(checker.byFirst[t.text[0]], t)
+		if len(t.text) > checker.maxTokenLen {
+			checker.maxTokenLen = len(t.text)
+		}
+	}
+
+	return checker
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// word tokens like begin/end only match as whole words, before and after are
+// the runes around the token or 0 at the start and end of the line
+func boundaryOK(t token, before, after rune) bool {
+	return !(t.wordStart && isWordRune(before)) && !(t.wordEnd && isWordRune(after))
+}
+
+func (c *Checker) matches(line string, offset int, t token) bool {
+	if !strings.HasPrefix(line[offset:], t.text) {
+		return false
+	}
+
+	before, after := rune(0), rune(0)
+	if offset > 0 {
+		before, _ = utf8.DecodeLastRuneInString(line[:offset])
+	}
+	if offset+len(t.text) < len(line) {
+		after, _ = utf8.DecodeRuneInString(line[offset+len(t.text):])
+	}
+
+	return boundaryOK(t, before, after)
+}
+
+// inside a quote only the escape and the closer of that quote count
+func (c *Checker) allowed(t token, inQuote int) bool {
+	if inQuote < 0 {
+		return t.kind != tokenEscape
+	}
+	if t.delimiter != inQuote {
+		return false
+	}
+	return t.kind != tokenOpen || t.text == c.language.Delimiters[inQuote].Close
+}
+
+// the token starting at offset
+func (c *Checker) tokenAt(line string, offset int, inQuote int) (token, bool) {
+	for _, t := range c.byFirst[line[offset]] {
+		if c.allowed(t, inQuote) && c.matches(line, offset, t) {
+			return t, true
+		}
+	}
+
+	return token{}, false
+}
+
+func (c *Checker) completion(stack []int, before, after rune) (string, []uint8) {
+	return closers(c.language, stack, before, after)
+}
+
+// the closers of stack, innermost first, with a space wherever a word token would run into a
+// word rune, so the text lexes back into the same tokens. before and after are the runes
+// around the insertion, 0 at the ends of the line
+func closers[S int | uint8](language *Language, stack []S, before, after rune) (string, []uint8) {
+	var completion strings.Builder
+	missing := make([]uint8, 0, len(stack))
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		closer := language.Delimiters[stack[i]].Close
+		first, _ := utf8.DecodeRuneInString(closer)
+
+		if isWordRune(before) && isWordRune(first) {
+			completion.WriteByte(' ')
+		}
+
+		completion.WriteString(closer)
+		before, _ = utf8.DecodeLastRuneInString(closer)
+		missing = append(missing, uint8(stack[i]))
+	}
+
+	if isWordRune(before) && isWordRune(after) {
+		completion.WriteByte(' ')
+	}
+
+	return completion.String(), missing
+}
+
+// the completion at the end of the line, a line that stops right after an escape gets a
+// space for the escape first, or it would swallow the first closer
+func (c *Checker) completeLine(stack []int, l *lexer) (string, []uint8) {
+	before, _ := utf8.DecodeLastRuneInString(l.line)
+
+	if l.danglingEscape {
+		completion, missing := c.completion(stack, ' ', 0)
+		return " " + completion, missing
+	}
+
+	return c.completion(stack, before, 0)
+}
+
+type lexer struct {
+	checker *Checker
+	line    string
+	offset  int
+	column  int
+	// the line ends with an escape that has nothing left to escape
+	danglingEscape bool
+}
+
+// the next delimiter token with its byte offset and column, skipping other text and
+// escaped characters, false at the end of the line
+func (l *lexer) next(inQuote int) (token, int, int, bool) {
+	for l.offset < len(l.line) {
+		t, ok := l.checker.tokenAt(l.line, l.offset, inQuote)
+
+		if !ok {
+			_, size := utf8.DecodeRuneInString(l.line[l.offset:])
+			l.offset += size
+			l.column++
+			continue
+		}
+
+		offset, column := l.offset, l.column
+		l.offset += len(t.text)
+		l.column += utf8.RuneCountInString(t.text)
+
+		if t.kind == tokenEscape {
+			if l.offset < len(l.line) {
+				_, size := utf8.DecodeRuneInString(l.line[l.offset:])
+				l.offset += size
+				l.column++
+			} else {
+				l.danglingEscape = true
+			}
+			continue
+		}
+
+		return t, offset, column, true
+	}
+
+	return token{}, 0, 0, false
+}
+
+func (c *Checker) lex(line string) *lexer {
+	return &lexer{c, line, 0, 1, false}
+}
+
+// the quote delimiter the top of the stack is in, or -1
+func (c *Checker) quoteOf(stack []int) int {
+	if len(stack) > 0 && c.language.Delimiters[stack[len(stack)-1]].Quote {
+		return stack[len(stack)-1]
+	}
+	return -1
+}
+
+// anything that is not a delimiter token is skipped
+func (c *Checker) Check(line string) Result {
+	stack := []int{}
+	lexer := c.lex(line)
+
+	for {
+		inQuote := c.quoteOf(stack)
+		t, offset, column, ok := lexer.next(inQuote)
+
+		if !ok {
+			break
+		}
+
+		if t.kind == tokenOpen && t.delimiter != inQuote {
+			stack = append(stack, t.delimiter)
+			continue
+		}
+
+		if len(stack) == 0 {
+			return Result{
+				Status: StatusCorrupted,
+				Offset: offset,
+				Column: column,
+				Found:  t.text,
+				Repair: c.Fix(line, RecoverInsert),
+				found:  t.delimiter,
+			}
+		}
+
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if top != t.delimiter {
+			return Result{
+				Status:   StatusCorrupted,
+				Offset:   offset,
+				Column:   column,
+				Expected: c.language.Delimiters[top].Close,
+				Found:    t.text,
+				Repair:   c.Fix(line, RecoverInsert),
+				found:    t.delimiter,
+			}
+		}
+	}
+
+	if len(stack) == 0 {
+		return Result{Status: StatusOK, Offset: -1, Column: -1, found: -1}
+	}
+
+	completion, missing := c.completeLine(stack, lexer)
+
+	return Result{
+		Status:     StatusIncomplete,
+		Offset:     len(line),
+		Column:     lexer.column,
+		Expected:   c.language.Delimiters[stack[len(stack)-1]].Close,
+		Completion: completion,
+		Repair:     line + completion,
+		found:      -1,
+		missing:    missing,
+	}
+}
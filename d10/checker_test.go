@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// word delimiters next to the puzzle ones, and a quote with an escape
+func testLanguage() *Language {
+	language := PuzzleLanguage()
+	language.Delimiters = append(language.Delimiters,
+		Delimiter{Open: "begin", Close: "end", CorruptionScore: 1, CompletionScore: 5},
+		Delimiter{Open: `"`, Close: `"`, CorruptionScore: 1, CompletionScore: 6, Quote: true, Escape: `\`},
+	)
+	return language
+}
+
+func assertFixBalances(t *testing.T, checker *Checker, line string) {
+	t.Helper()
+
+	for _, strategy := range []RecoveryStrategy{RecoverSkip, RecoverInsert} {
+		fixed := checker.Fix(line, strategy)
+		if result := checker.Check(fixed); result.Status != StatusOK {
+			t.Fatalf("Fix(%q, %d) = %q is %v at column %d", line, strategy, fixed, result.Status, result.Column)
+		}
+	}
+
+	if result := checker.Check(line); result.Status != StatusOK {
+		if repaired := checker.Check(result.Repair); repaired.Status != StatusOK {
+			t.Fatalf("Repair %q of %q is %v at column %d", result.Repair, line, repaired.Status, repaired.Column)
+		}
+	}
+}
+
+func TestFixBalances(t *testing.T) {
+	checker := NewLanguageChecker(testLanguage())
+
+	lines := []string{
+		"begin begin x",
+		"begin (x end",
+		"begin x) end",
+		"be)gin",
+		`"abc\`,
+		`("abc\`,
+		`[<"a\"b`,
+		"{([(<{}[<>[]}>{[]{[(<()>",
+		"[[<[([]))<([[{}[[()]]]",
+	}
+
+	for _, line := range lines {
+		assertFixBalances(t, checker, line)
+	}
+
+	if got := checker.Check("begin begin x").Completion; got != " end end" {
+		t.Errorf(`completion of "begin begin x" is %q, expected " end end"`, got)
+	}
+
+	pieces := []string{"(", ")", "[", "]", "{", "}", "<", ">", "begin", "end", `"`, `\`, "x", " ", "e", "nd"}
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		var line strings.Builder
+		for n := r.Intn(12); n > 0; n-- {
+			line.WriteString(pieces[r.Intn(len(pieces))])
+		}
+
+		assertFixBalances(t, checker, line.String())
+	}
+}
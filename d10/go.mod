@@ -0,0 +1,3 @@
+module aocd10
+
+go 1.18
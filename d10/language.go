@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+)
+
+type Delimiter struct {
+	Open            string `json:"open"`
+	Close           string `json:"close"`
+	CorruptionScore int    `json:"corruption_score"`
+	CompletionScore int    `json:"completion_score"`
+	// nothing but Escape and Close is recognised between the quotes, Open and Close may be the same
+	Quote bool `json:"quote,omitempty"`
+	// inside a quote the character after Escape is skipped
+	Escape string `json:"escape,omitempty"`
+}
+
+type Language struct {
+	Delimiters []Delimiter `json:"delimiters"`
+	// completion scores are built as score*CompletionMultiplier + closer score
+	CompletionMultiplier int `json:"completion_multiplier"`
+}
+
+func PuzzleLanguage() *Language {
+	return &Language{
+		Delimiters: []Delimiter{
+			{Open: "(", Close: ")", CorruptionScore: 3, CompletionScore: 1},
+			{Open: "[", Close: "]", CorruptionScore: 57, CompletionScore: 2},
+			{Open: "{", Close: "}", CorruptionScore: 1197, CompletionScore: 3},
+			{Open: "<", Close: ">", CorruptionScore: 25137, CompletionScore: 4},
+		},
+		CompletionMultiplier: 5,
+	}
+}
+
+func ParseLanguage(r io.Reader) (*Language, error) {
+	language := &Language{}
+
+	if err := json.NewDecoder(r).Decode(language); err != nil {
+		return nil, err
+	}
+
+	if err := language.Validate(); err != nil {
+		return nil, err
+	}
+
+	return language, nil
+}
+
+func LoadLanguage(path string) (*Language, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseLanguage(f)
+}
+
+// every token may only mean one thing, except for quotes that open and close with the same token
+func (l *Language) Validate() error {
+	if len(l.Delimiters) == 0 {
+		return fmt.Errorf("language has no delimiters")
+	}
+
+	// open delimiters are kept as one byte each
+	if len(l.Delimiters) > 256 {
+		return fmt.Errorf("language has %d delimiters, at most 256 are supported", len(l.Delimiters))
+	}
+
+	if l.CompletionMultiplier < 1 {
+		return fmt.Errorf("completion multiplier must be at least 1, got %d", l.CompletionMultiplier)
+	}
+
+	tokens := map[string]int{}
+
+	for i, d := range l.Delimiters {
+		if d.Open == "" || d.Close == "" {
+			return fmt.Errorf("delimiter %d: open and close must not be empty", i+1)
+		}
+
+		if d.Open == d.Close && !d.Quote {
+			return fmt.Errorf("delimiter %d: %q opens and closes, only quotes may do that", i+1, d.Open)
+		}
+
+		if d.CorruptionScore < 0 || d.CompletionScore < 0 {
+			return fmt.Errorf("delimiter %d: scores must not be negative", i+1)
+		}
+
+		if d.Escape != "" && !d.Quote {
+			return fmt.Errorf("delimiter %d: only quotes can have an escape", i+1)
+		}
+
+		for _, token := range []string{d.Open, d.Close} {
+			if other, ok := tokens[token]; ok && other != i {
+				return fmt.Errorf("delimiter %d: %q is already used by delimiter %d", i+1, token, other+1)
+			}
+			tokens[token] = i
+		}
+	}
+
+	return nil
+}
+
+func (l *Language) CorruptionScore(result Result) int {
+	if result.Status != StatusCorrupted || result.found < 0 {
+		return 0
+	}
+	return l.Delimiters[result.found].CorruptionScore
+}
+
+// exact for completions of any length, the score is built in uint64 chunks that are
+// only folded into the big.Int when the next token would overflow them
+func (l *Language) CompletionScore(result Result) *big.Int {
+	score := new(big.Int)
+	multiplier := uint64(l.CompletionMultiplier)
+	chunk, chunkScale := uint64(0), uint64(1)
+	scratch := new(big.Int)
+
+	flush := func() {
+		score.Mul(score, scratch.SetUint64(chunkScale))
+		score.Add(score, scratch.SetUint64(chunk))
+		chunk, chunkScale = 0, 1
+	}
+
+	for _, i := range result.missing {
+		value := uint64(l.Delimiters[i].CompletionScore)
+
+		if chunkScale > math.MaxUint64/multiplier || chunk > (math.MaxUint64-value)/multiplier {
+			flush()
+		}
+
+		chunk = chunk*multiplier + value
+		chunkScale *= multiplier
+	}
+
+	flush()
+
+	return score
+}
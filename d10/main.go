@@ -2,23 +2,36 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math/big"
 	"os"
-	"sort"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 )
 
+// unlike bufio.Scanner there is no limit on the length of a line, line endings are
+// stripped the same way, \r\n included
 func readLines(reader io.Reader) ([]string, error) {
-	scanner := bufio.NewScanner(reader)
+	in := bufio.NewReader(reader)
 	lines := []string{}
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	for {
+		line, err := in.ReadString('\n')
+		if len(line) > 0 {
+			lines = append(lines, strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
-	return lines, nil
 }
 
 type AdventOfCodeDataSource interface {
@@ -41,6 +54,8 @@ func (dataSource AdventOfCodeFileDataSourceDay10) Read() ([]string, error) {
 type AdventOfCodeDay10Solution struct {
 	dataSource AdventOfCodeDataSource
 	data       []string
+	// nil means the four bracket pairs of the puzzle
+	language *Language
 }
 
 func (solution *AdventOfCodeDay10Solution) Data() []string {
@@ -57,110 +72,303 @@ func (solution *AdventOfCodeDay10Solution) Data() []string {
 	return solution.data
 }
 
-func getFirstIllegalRune(line string) (rune, []rune) {
-	stack := []rune{}
+func solutionLanguage(language *Language) *Language {
+	if language == nil {
+		return PuzzleLanguage()
+	}
+	return language
+}
 
-	for _, ch := range line {
-		if ch == '(' || ch == '[' || ch == '{' || ch == '<' {
-			stack = append(stack, ch)
-		} else {
-			if len(stack) == 0 {
-				return ch, nil
-			}
+func (solution AdventOfCodeDay10Solution) Language() *Language {
+	return solutionLanguage(solution.language)
+}
 
-			top := stack[len(stack)-1]
-			stack = stack[:len(stack)-1]
+func (solution AdventOfCodeDay10Solution) Part1() int {
+	lines := solution.Data()
+	language := solution.Language()
+	checker := NewLanguageChecker(language)
+	total := 0
 
-			if ch == ')' && top == '(' {
-				continue
-			}
+	for _, line := range lines {
+		total += language.CorruptionScore(checker.Check(line))
+	}
 
-			if ch == ']' && top == '[' {
-				continue
-			}
+	return total
+}
 
-			if ch == '}' && top == '{' {
-				continue
-			}
+func (solution AdventOfCodeDay10Solution) Part2Score(policy MedianPolicy) (*big.Int, error) {
+	lines := solution.Data()
+	language := solution.Language()
+	checker := NewLanguageChecker(language)
+	scores := []*big.Int{}
+
+	for _, line := range lines {
+		result := checker.Check(line)
+
+		if result.Status == StatusIncomplete {
+			scores = append(scores, language.CompletionScore(result))
+		}
+	}
+
+	return MedianScore(scores, len(lines), policy)
+}
+
+func (solution AdventOfCodeDay10Solution) Part2() int {
+	score, err := solution.Part2Score(MedianUpper)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !score.IsInt64() || int64(int(score.Int64())) != score.Int64() {
+		log.Fatalf("completion score %v does not fit into an int, use Part2Score", score)
+	}
+
+	return int(score.Int64())
+}
+
+// go run . lint [-recover skip|insert] file ... prints file:line:column: message for every error,
+// and fails if there was any
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	languagePath := flags.String("language", "", "JSON file with the delimiter pairs and their scores")
+	recovery := flags.String("recover", "insert", "how linting continues after an error, \"skip\" or \"insert\"")
+	flags.Parse(args)
+
+	strategy, err := parseRecoveryStrategy(*recovery)
+	if err != nil {
+		return err
+	}
+
+	var language *Language
+	if *languagePath != "" {
+		if language, err = LoadLanguage(*languagePath); err != nil {
+			return err
+		}
+	}
 
-			if ch == '>' && top == '<' {
-				continue
+	checker := NewLanguageChecker(solutionLanguage(language))
+	clean := true
+
+	for _, path := range flags.Args() {
+		lines, err := AdventOfCodeFileDataSourceDay10{path}.Read()
+		if err != nil {
+			return err
+		}
+
+		for i, line := range lines {
+			report := checker.CheckAll(line, strategy)
+
+			for _, syntaxError := range report.Errors {
+				fmt.Printf("%s:%d:%d: %s\n", path, i+1, syntaxError.Column, syntaxError.Message())
+				clean = false
 			}
 
-			return ch, nil
+			if !report.OK() && report.EditDistance >= 0 {
+				fmt.Printf("%s:%d: balanced with %d edits\n", path, i+1, report.EditDistance)
+			}
 		}
 	}
 
-	return 0, stack
+	if !clean {
+		return errors.New("delimiter errors found")
+	}
+
+	return nil
 }
 
-func (solution AdventOfCodeDay10Solution) Part1() int {
-	lines := solution.Data()
+// go run . fix [-w] [file ...] prints the repaired lines, or with -w rewrites the files,
+// and repairs standard input when no file is given
+func runFix(args []string) error {
+	flags := flag.NewFlagSet("fix", flag.ExitOnError)
+	inPlace := flags.Bool("w", false, "rewrite the files in place instead of printing them")
+	languagePath := flags.String("language", "", "JSON file with the delimiter pairs and their scores")
+	recovery := flags.String("recover", "insert", "how errors are repaired, \"skip\" or \"insert\"")
+	flags.Parse(args)
 
-	/*
+	strategy, err := parseRecoveryStrategy(*recovery)
+	if err != nil {
+		return err
+	}
 
-		): 3 points
-		]: 57 points
-		}: 1197 points
-		>: 25137 points
+	var language *Language
+	if *languagePath != "" {
+		if language, err = LoadLanguage(*languagePath); err != nil {
+			return err
+		}
+	}
 
-	*/
+	checker := NewLanguageChecker(solutionLanguage(language))
 
-	points := make(map[rune]int)
+	if flags.NArg() == 0 {
+		lines, err := readLines(os.Stdin)
+		if err != nil {
+			return err
+		}
+		return writeFixed(os.Stdout, checker, lines, strategy)
+	}
 
-	points[')'] = 3
-	points[']'] = 57
-	points['}'] = 1197
-	points['>'] = 25137
+	for _, path := range flags.Args() {
+		lines, err := AdventOfCodeFileDataSourceDay10{path}.Read()
+		if err != nil {
+			return err
+		}
 
-	total := 0
+		if !*inPlace {
+			if err := writeFixed(os.Stdout, checker, lines, strategy); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := rewriteFixed(path, checker, lines, strategy); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFixed(w io.Writer, checker *Checker, lines []string, strategy RecoveryStrategy) error {
+	out := bufio.NewWriter(w)
 
 	for _, line := range lines {
-		r, _ := getFirstIllegalRune(line)
-		total += points[r]
+		out.WriteString(checker.Fix(line, strategy))
+		out.WriteByte('\n')
 	}
 
-	return total
+	return out.Flush()
 }
 
-func (solution AdventOfCodeDay10Solution) Part2() int {
-	lines := solution.Data()
+// writes next to the original and renames over it, so a failed write never leaves half a file
+func rewriteFixed(path string, checker *Checker, lines []string, strategy RecoveryStrategy) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
 
-	points := make(map[rune]int)
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".fix-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
 
-	points['('] = 1
-	points['['] = 2
-	points['{'] = 3
-	points['<'] = 4
+	if err := writeFixed(tmp, checker, lines, strategy); err != nil {
+		tmp.Close()
+		return err
+	}
 
-	scores := []int{}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
 
-	for _, line := range lines {
-		_, incompleteLine := getFirstIllegalRune(line)
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
 
-		if incompleteLine != nil {
-			score := 0
+	return os.Rename(tmp.Name(), path)
+}
 
-			for i := len(incompleteLine) - 1; i >= 0; i-- {
-				ch := incompleteLine[i]
-				score *= 5
-				score += points[ch]
-			}
+func parseRecoveryStrategy(name string) (RecoveryStrategy, error) {
+	switch name {
+	case "insert":
+		return RecoverInsert, nil
+	case "skip":
+		return RecoverSkip, nil
+	}
+	return RecoverInsert, fmt.Errorf("unknown recovery %q, expected skip or insert", name)
+}
 
-			scores = append(scores, score)
+// go run . check [-workers n] [-progress] file ... streams every file and prints a summary per file
+func runCheck(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	workers := flags.Int("workers", runtime.NumCPU(), "files checked at the same time")
+	showProgress := flags.Bool("progress", false, "report the bytes read of each file on stderr")
+	languagePath := flags.String("language", "", "JSON file with the delimiter pairs and their scores")
+	flags.Parse(args)
+
+	var language *Language
+	if *languagePath != "" {
+		var err error
+		if language, err = LoadLanguage(*languagePath); err != nil {
+			return err
 		}
 	}
 
-	sort.Ints(scores)
+	var progress func(path string, bytes int64)
+	if *showProgress {
+		var mu sync.Mutex
+		progress = func(path string, bytes int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			fmt.Fprintf(os.Stderr, "%s: %d bytes\n", path, bytes)
+		}
+	}
+
+	checker := NewLanguageChecker(solutionLanguage(language))
+	failed := false
+
+	for _, summary := range CheckFiles(flags.Args(), checker, *workers, progress) {
+		if summary.Err != nil {
+			fmt.Printf("%s: %v\n", summary.Path, summary.Err)
+			failed = true
+			continue
+		}
+
+		fmt.Printf("%s: %d lines, %d corrupted, %d incomplete, syntax error score %d\n",
+			summary.Path, summary.Lines, summary.Corrupted, summary.Incomplete, summary.CorruptionScore)
+	}
 
-	index := len(scores) / 2
+	if failed {
+		return errors.New("some files could not be checked")
+	}
 
-	return scores[index]
+	return nil
 }
 
 func main() {
+	commands := map[string]func([]string) error{
+		"fix":   runFix,
+		"check": runCheck,
+		"lint":  runLint,
+	}
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	languagePath := flag.String("language", "", "JSON file with the delimiter pairs and their scores")
+	median := flag.String("median", "upper", "middle score for an even number of incomplete lines: upper, lower, mean or odd")
+	flag.Parse()
+
+	var language *Language
+
+	if *languagePath != "" {
+		var err error
+		language, err = LoadLanguage(*languagePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	fileDataSource := AdventOfCodeFileDataSourceDay10{"test_data2"}
-	solution := AdventOfCodeDay10Solution{fileDataSource, nil}
+	solution := AdventOfCodeDay10Solution{fileDataSource, nil, language}
+	policy, err := ParseMedianPolicy(*median)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	fmt.Println(solution.Part1())
-	fmt.Println(solution.Part2())
+
+	score, err := solution.Part2Score(policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(score)
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type RecoveryStrategy int
+
+const (
+	// drop a closer that does not match the innermost open delimiter
+	RecoverSkip RecoveryStrategy = iota
+	// close the delimiters above a matching open one, as if their closers had been forgotten,
+	// and only drop the closer when nothing open matches it
+	RecoverInsert
+)
+
+type SyntaxError struct {
+	Offset   int
+	Column   int
+	Expected string
+	// empty when the error is the end of an incomplete line
+	Found string
+	// what the recovery did: the closers it inserted in front of Found, or
+	// whether it skipped Found
+	Inserted string
+	Skipped  bool
+}
+
+type Report struct {
+	Errors []SyntaxError
+	// the minimal number of inserted, deleted or replaced delimiters that
+	// balances the line, -1 when the line has too many delimiters to compute it
+	EditDistance int
+}
+
+func (r Report) OK() bool {
+	return len(r.Errors) == 0
+}
+
+// the edit distance is cubic in the number of delimiter tokens, longer lines are not measured
+const maxEditDistanceTokens = 500
+
+// keeps checking after a mismatch and reports every error of the line, the
+// unclosed delimiters at the end are reported as one last error
+func (c *Checker) CheckAll(line string, strategy RecoveryStrategy) Report {
+	return Report{c.syntaxErrors(line, strategy), c.EditDistance(line)}
+}
+
+// the errors of CheckAll without the edit distance, which is far more work than the errors
+func (c *Checker) syntaxErrors(line string, strategy RecoveryStrategy) []SyntaxError {
+	stack := []int{}
+	errors := []SyntaxError{}
+	lexer := c.lex(line)
+
+	for {
+		inQuote := c.quoteOf(stack)
+		t, offset, column, ok := lexer.next(inQuote)
+
+		if !ok {
+			break
+		}
+
+		if t.kind == tokenOpen && t.delimiter != inQuote {
+			stack = append(stack, t.delimiter)
+			continue
+		}
+
+		if len(stack) > 0 && stack[len(stack)-1] == t.delimiter {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		syntaxError := SyntaxError{Offset: offset, Column: column, Found: t.text}
+		if len(stack) > 0 {
+			syntaxError.Expected = c.language.Delimiters[stack[len(stack)-1]].Close
+		}
+
+		match := -1
+		if strategy == RecoverInsert {
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == t.delimiter {
+					match = i
+					break
+				}
+			}
+		}
+
+		if match >= 0 {
+			before, _ := utf8.DecodeLastRuneInString(line[:offset])
+			after, _ := utf8.DecodeRuneInString(line[offset:])
+			syntaxError.Inserted, _ = c.completion(stack[match+1:], before, after)
+			stack = stack[:match]
+		} else {
+			syntaxError.Skipped = true
+		}
+
+		errors = append(errors, syntaxError)
+	}
+
+	if len(stack) > 0 {
+		completion, _ := c.completeLine(stack, lexer)
+		errors = append(errors, SyntaxError{
+			Offset:   len(line),
+			Column:   lexer.column,
+			Expected: c.language.Delimiters[stack[len(stack)-1]].Close,
+			Inserted: completion,
+		})
+	}
+
+	return errors
+}
+
+// the line with every error of CheckAll repaired: missing closers inserted, unmatched
+// closers removed and unclosed delimiters closed at the end
+func (c *Checker) Fix(line string, strategy RecoveryStrategy) string {
+	var fixed strings.Builder
+	last := 0
+
+	for _, syntaxError := range c.syntaxErrors(line, strategy) {
+		writeSeparated(&fixed, line[last:syntaxError.Offset])
+		writeSeparated(&fixed, syntaxError.Inserted)
+		last = syntaxError.Offset
+
+		if syntaxError.Skipped {
+			last += len(syntaxError.Found)
+		}
+	}
+
+	writeSeparated(&fixed, line[last:])
+
+	return fixed.String()
+}
+
+// a dropped token can bring two words together, which then might lex as a new token
+func writeSeparated(fixed *strings.Builder, text string) {
+	before, _ := utf8.DecodeLastRuneInString(fixed.String())
+	first, _ := utf8.DecodeRuneInString(text)
+
+	if isWordRune(before) && isWordRune(first) {
+		fixed.WriteByte(' ')
+	}
+
+	fixed.WriteString(text)
+}
+
+type delimiterToken struct {
+	delimiter int
+	open      bool
+}
+
+// the delimiters of the line in order, quotes count as one open and one close token
+func (c *Checker) delimiterTokens(line string) []delimiterToken {
+	tokens := []delimiterToken{}
+	inQuote := -1
+	lexer := c.lex(line)
+
+	for {
+		t, _, _, ok := lexer.next(inQuote)
+		if !ok {
+			return tokens
+		}
+
+		open := t.kind == tokenOpen && t.delimiter != inQuote
+		tokens = append(tokens, delimiterToken{t.delimiter, open})
+
+		if c.language.Delimiters[t.delimiter].Quote {
+			if open {
+				inQuote = t.delimiter
+			} else {
+				inQuote = -1
+			}
+		}
+	}
+}
+
+// interval dp over the delimiter tokens, dp[i][j] balances tokens i..j-1: token i is either
+// deleted, or paired with a later token k, which costs one replacement for each side that
+// does not fit the pair
+func (c *Checker) EditDistance(line string) int {
+	tokens := c.delimiterTokens(line)
+	n := len(tokens)
+
+	if n > maxEditDistanceTokens {
+		return -1
+	}
+
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, n+1)
+	}
+
+	for length := 1; length <= n; length++ {
+		for i := 0; i+length <= n; i++ {
+			j := i + length
+			best := dp[i+1][j] + 1
+
+			for k := i + 1; k < j; k++ {
+				cost := 0
+				if !tokens[i].open {
+					cost++
+				}
+				if tokens[k].open {
+					cost++
+				}
+				if cost == 0 && tokens[i].delimiter != tokens[k].delimiter {
+					cost = 1
+				}
+
+				if total := cost + dp[i+1][k] + dp[k+1][j]; total < best {
+					best = total
+				}
+			}
+
+			dp[i][j] = best
+		}
+	}
+
+	return dp[0][n]
+}
+
+// the error without its position
+func (e SyntaxError) Message() string {
+	var message string
+
+	switch {
+	case e.Found == "":
+		message = fmt.Sprintf("unclosed, expected %q", e.Expected)
+	case e.Expected == "":
+		message = fmt.Sprintf("unexpected %q", e.Found)
+	default:
+		message = fmt.Sprintf("expected %q, found %q", e.Expected, e.Found)
+	}
+
+	if e.Inserted != "" {
+		message += fmt.Sprintf(", inserted %q", e.Inserted)
+	}
+	if e.Skipped {
+		message += fmt.Sprintf(", skipped %q", e.Found)
+	}
+
+	return message
+}
+
+func (e SyntaxError) String() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message())
+}
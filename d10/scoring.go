@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+type MedianPolicy int
+
+const (
+	// the upper of the two middle scores when the count is even
+	MedianUpper MedianPolicy = iota
+	// the lower of the two middle scores when the count is even
+	MedianLower
+	// the mean of the two middle scores when the count is even, rounded down
+	MedianMean
+	// an even count is an error, the puzzle promises an odd number of incomplete lines
+	MedianOddOnly
+)
+
+type NoIncompleteLinesError struct {
+	Lines int
+}
+
+func (e NoIncompleteLinesError) Error() string {
+	return fmt.Sprintf("none of the %d lines is incomplete, there is no completion score", e.Lines)
+}
+
+type EvenScoreCountError struct {
+	Count int
+}
+
+func (e EvenScoreCountError) Error() string {
+	return fmt.Sprintf("%d completion scores have no single middle score", e.Count)
+}
+
+func ParseMedianPolicy(name string) (MedianPolicy, error) {
+	switch name {
+	case "upper":
+		return MedianUpper, nil
+	case "lower":
+		return MedianLower, nil
+	case "mean":
+		return MedianMean, nil
+	case "odd":
+		return MedianOddOnly, nil
+	}
+	return MedianUpper, fmt.Errorf("unknown median policy %q, expected upper, lower, mean or odd", name)
+}
+
+// scores is sorted in place, lines is only used for the error when scores is empty
+func MedianScore(scores []*big.Int, lines int, policy MedianPolicy) (*big.Int, error) {
+	if len(scores) == 0 {
+		return nil, NoIncompleteLinesError{lines}
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Cmp(scores[j]) < 0
+	})
+
+	upper := scores[len(scores)/2]
+
+	if len(scores)%2 == 1 {
+		return new(big.Int).Set(upper), nil
+	}
+
+	lower := scores[len(scores)/2-1]
+
+	switch policy {
+	case MedianLower:
+		return new(big.Int).Set(lower), nil
+	case MedianMean:
+		mean := new(big.Int).Add(lower, upper)
+		return mean.Rsh(mean, 1), nil
+	case MedianOddOnly:
+		return nil, EvenScoreCountError{len(scores)}
+	}
+
+	return new(big.Int).Set(upper), nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+type StreamOptions struct {
+	// called with the number of bytes read so far, every ProgressInterval bytes and once at the end
+	Progress         func(bytes int64)
+	ProgressInterval int64
+}
+
+// checks lines of any length straight from a reader, only the open delimiters of
+// the current line are kept, one byte each
+type StreamChecker struct {
+	checker *Checker
+	options StreamOptions
+}
+
+func NewStreamChecker(checker *Checker, options StreamOptions) *StreamChecker {
+	if options.ProgressInterval <= 0 {
+		options.ProgressInterval = 1 << 20
+	}
+
+	return &StreamChecker{checker, options}
+}
+
+type streamState struct {
+	in           *bufio.Reader
+	read         int64
+	lastProgress int64
+	options      StreamOptions
+}
+
+func (s *streamState) discard(n int) {
+	s.in.Discard(n)
+	s.advance(n)
+}
+
+func (s *streamState) advance(n int) {
+	s.read += int64(n)
+
+	if s.options.Progress != nil && s.read-s.lastProgress >= s.options.ProgressInterval {
+		s.lastProgress = s.read
+		s.options.Progress(s.read)
+	}
+}
+
+// skips to the start of the next line, false at the end of the input
+func (s *streamState) skipLine() (bool, error) {
+	for {
+		chunk, err := s.in.ReadSlice('\n')
+		s.advance(len(chunk))
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			return false, nil
+		}
+		return err == nil, err
+	}
+}
+
+// the token at the start of window, window never crosses the end of the line
+func (c *Checker) tokenAtBytes(window []byte, before rune, inQuote int) (token, bool) {
+	for _, t := range c.byFirst[window[0]] {
+		if len(window) < len(t.text) || string(window[:len(t.text)]) != t.text || !c.allowed(t, inQuote) {
+			continue
+		}
+
+		after := rune(0)
+		if t.wordEnd && len(window) > len(t.text) {
+			after, _ = utf8.DecodeRune(window[len(t.text):])
+		}
+
+		if boundaryOK(t, before, after) {
+			return t, true
+		}
+	}
+
+	return token{}, false
+}
+
+// calls visit with the 1-based line number and result of every line, Repair is left empty
+// because the line itself is never held in memory
+func (s *StreamChecker) Check(r io.Reader, visit func(line int, result Result) error) error {
+	c := s.checker
+	state := &streamState{in: bufio.NewReaderSize(r, 1<<16), options: s.options}
+	stack := []uint8{}
+	lineNo := 0
+
+	for more := true; more; {
+		lineNo++
+		stack = stack[:0]
+		offset, column := 0, 1
+		before := rune(0)
+		danglingEscape := false
+		var result *Result
+
+		for {
+			window, err := state.in.Peek(c.maxTokenLen + utf8.UTFMax)
+			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+				return err
+			}
+
+			if len(window) == 0 {
+				more = false
+				break
+			}
+
+			// like bufio.ScanLines a \r right before the end of the line belongs to the line ending
+			end := bytes.IndexByte(window, '\n')
+			if end < 0 && err == io.EOF {
+				end = len(window)
+			}
+			if end > 0 && window[end-1] == '\r' {
+				end--
+			}
+
+			if end == 0 {
+				n := 0
+				if window[0] == '\r' {
+					n++
+				}
+				if n < len(window) && window[n] == '\n' {
+					n++
+				}
+				state.discard(n)
+				break
+			} else if end > 0 {
+				window = window[:end]
+			}
+
+			inQuote := -1
+			if len(stack) > 0 && c.language.Delimiters[stack[len(stack)-1]].Quote {
+				inQuote = int(stack[len(stack)-1])
+			}
+
+			t, ok := c.tokenAtBytes(window, before, inQuote)
+
+			if !ok {
+				ch, size := rune(window[0]), 1
+				if ch >= utf8.RuneSelf {
+					ch, size = utf8.DecodeRune(window)
+				}
+				state.discard(size)
+				offset += size
+				column++
+				before = ch
+				continue
+			}
+
+			state.discard(len(t.text))
+			tokenOffset, tokenColumn := offset, column
+			offset += len(t.text)
+			column += utf8.RuneCountInString(t.text)
+			if t.wordEnd {
+				before, _ = utf8.DecodeLastRuneInString(t.text)
+			} else {
+				before = 0
+			}
+
+			if t.kind == tokenEscape {
+				if len(window) > len(t.text) {
+					ch, size := utf8.DecodeRune(window[len(t.text):])
+					state.discard(size)
+					offset += size
+					column++
+					before = ch
+				} else {
+					danglingEscape = true
+				}
+				continue
+			}
+
+			if t.kind == tokenOpen && t.delimiter != inQuote {
+				stack = append(stack, uint8(t.delimiter))
+				continue
+			}
+
+			if len(stack) == 0 || int(stack[len(stack)-1]) != t.delimiter {
+				result = &Result{Status: StatusCorrupted, Offset: tokenOffset, Column: tokenColumn, Found: t.text, found: t.delimiter}
+				if len(stack) > 0 {
+					result.Expected = c.language.Delimiters[stack[len(stack)-1]].Close
+				}
+
+				if more, err = state.skipLine(); err != nil {
+					return err
+				}
+				break
+			}
+
+			stack = stack[:len(stack)-1]
+		}
+
+		if result == nil && !more && offset == 0 && len(stack) == 0 {
+			break
+		}
+
+		if result == nil {
+			result = s.endOfLine(stack, offset, column, before, danglingEscape)
+		}
+
+		if err := visit(lineNo, *result); err != nil {
+			return err
+		}
+	}
+
+	if s.options.Progress != nil {
+		s.options.Progress(state.read)
+	}
+
+	return nil
+}
+
+// before is the last rune of the line, or 0 when it ended in a token that needs no word boundary
+func (s *StreamChecker) endOfLine(stack []uint8, offset, column int, before rune, danglingEscape bool) *Result {
+	if len(stack) == 0 {
+		return &Result{Status: StatusOK, Offset: -1, Column: -1, found: -1}
+	}
+
+	prefix := ""
+	if danglingEscape {
+		prefix, before = " ", ' '
+	}
+
+	completion, missing := closers(s.checker.language, stack, before, 0)
+
+	return &Result{
+		Status:     StatusIncomplete,
+		Offset:     offset,
+		Column:     column,
+		Expected:   s.checker.language.Delimiters[stack[len(stack)-1]].Close,
+		Completion: prefix + completion,
+		found:      -1,
+		missing:    missing,
+	}
+}
+
+type FileSummary struct {
+	Path       string
+	Lines      int
+	Corrupted  int
+	Incomplete int
+	// the Part1 and Part2 style scores of the file
+	CorruptionScore  int
+	CompletionScores []*big.Int
+	Err              error
+}
+
+// checks the files with up to workers at a time, progress gets the path and bytes read
+// of each file, summaries come back in the order of paths
+func CheckFiles(paths []string, checker *Checker, workers int, progress func(path string, bytes int64)) []FileSummary {
+	summaries := make([]FileSummary, len(paths))
+	jobs := make(chan int)
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				summaries[i] = checkFile(paths[i], checker, progress)
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return summaries
+}
+
+func checkFile(path string, checker *Checker, progress func(path string, bytes int64)) FileSummary {
+	summary := FileSummary{Path: path}
+
+	options := StreamOptions{}
+	if progress != nil {
+		options.Progress = func(bytes int64) { progress(path, bytes) }
+	}
+
+	stream := NewStreamChecker(checker, options)
+
+	f, err := os.Open(path)
+	if err != nil {
+		summary.Err = err
+		return summary
+	}
+	defer f.Close()
+
+	language := checker.language
+
+	summary.Err = stream.Check(f, func(_ int, result Result) error {
+		summary.Lines++
+
+		switch result.Status {
+		case StatusCorrupted:
+			summary.Corrupted++
+			summary.CorruptionScore += language.CorruptionScore(result)
+		case StatusIncomplete:
+			summary.Incomplete++
+			summary.CompletionScores = append(summary.CompletionScores, language.CompletionScore(result))
+		}
+
+		return nil
+	})
+
+	return summary
+}
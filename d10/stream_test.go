@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+// the stream never holds a line, so everything but Repair has to match Checker.Check
+func assertStreamMatchesCheck(t *testing.T, checker *Checker, lines []string, newline string) {
+	t.Helper()
+
+	input := strings.Join(lines, newline) + newline
+	results := []Result{}
+
+	stream := NewStreamChecker(checker, StreamOptions{})
+	err := stream.Check(strings.NewReader(input), func(line int, result Result) error {
+		if line != len(results)+1 {
+			t.Fatalf("line %d reported after %d lines", line, len(results))
+		}
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(lines) {
+		t.Fatalf("stream checked %d lines, expected %d", len(results), len(lines))
+	}
+
+	for i, line := range lines {
+		want := checker.Check(line)
+		want.Repair = ""
+
+		got := results[i]
+		if got.Status != want.Status || got.Offset != want.Offset || got.Column != want.Column ||
+			got.Expected != want.Expected || got.Found != want.Found || got.Completion != want.Completion {
+			t.Fatalf("line %d %q with %q endings: stream %+v, Check %+v", i+1, line, newline, got, want)
+		}
+	}
+}
+
+func TestStreamMatchesCheck(t *testing.T) {
+	data, err := AdventOfCodeFileDataSourceDay10{"test_data2"}.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle := NewChecker()
+	checker := NewLanguageChecker(testLanguage())
+
+	pieces := []string{"(", ")", "[", "]", "{", "}", "<", ">", "begin", "end", `"`, `\`, "x", " ", "e", "nd", "é"}
+	r := rand.New(rand.NewSource(1))
+	lines := []string{}
+
+	for i := 0; i < 5000; i++ {
+		var line strings.Builder
+		for n := 1 + r.Intn(12); n > 0; n-- {
+			line.WriteString(pieces[r.Intn(len(pieces))])
+		}
+		lines = append(lines, line.String())
+	}
+
+	for _, newline := range []string{"\n", "\r\n"} {
+		assertStreamMatchesCheck(t, puzzle, data, newline)
+		assertStreamMatchesCheck(t, checker, lines, newline)
+	}
+}
+
+func TestStreamReportsProgressWhileSkipping(t *testing.T) {
+	// corrupted at the first byte, the rest of the line is skipped without being lexed
+	input := ")" + strings.Repeat("(", 1<<20) + "\n"
+
+	reports := 0
+	stream := NewStreamChecker(NewChecker(), StreamOptions{
+		Progress:         func(bytes int64) { reports++ },
+		ProgressInterval: 1 << 16,
+	})
+
+	if err := stream.Check(strings.NewReader(input), func(int, Result) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if reports < 16 {
+		t.Errorf("%d progress reports for %d bytes every %d bytes", reports, len(input), 1<<16)
+	}
+}
+
+func TestReadLinesStripsCRLF(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "crlf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("[(\r\n{}\r\n<>\r")
+	f.Close()
+
+	lines, err := AdventOfCodeFileDataSourceDay10{f.Name()}.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(lines, "|") != "[(|{}|<>" {
+		t.Errorf("read %q", lines)
+	}
+}
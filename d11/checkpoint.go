@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"aocgrid"
+)
+
+// bumped whenever the meaning of a field changes, older files are rejected instead of misread
+const checkpointVersion = 1
+
+// everything needed to continue a simulation, the history is not saved and starts
+// again at Step after a resume
+type Checkpoint struct {
+	Version int     `json:"version"`
+	Step    int     `json:"step"`
+	Flashes int     `json:"flashes"`
+	Rules   *Rules  `json:"rules"`
+	Initial [][]int `json:"initial"`
+	Grid    [][]int `json:"grid"`
+}
+
+func gridRows(g *Grid) [][]int {
+	rows := make([][]int, g.cells.Rows())
+
+	g.cells.Each(func(row, _, val int) {
+		rows[row] = append(rows[row], val)
+	})
+
+	return rows
+}
+
+func (simulation *FlashSimulation) Checkpoint() Checkpoint {
+	return Checkpoint{
+		Version: checkpointVersion,
+		Step:    simulation.step,
+		Flashes: simulation.flashes,
+		Rules:   simulation.rules,
+		Initial: gridRows(simulation.initial),
+		Grid:    gridRows(simulation.current),
+	}
+}
+
+func (simulation *FlashSimulation) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(simulation.Checkpoint())
+}
+
+// written to a temporary file next to path first, so a failed save never leaves half a checkpoint.
+// An existing checkpoint keeps its file mode
+func (simulation *FlashSimulation) SaveFile(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".save-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := simulation.Save(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func ReadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+
+	if err := json.NewDecoder(r).Decode(checkpoint); err != nil {
+		return nil, err
+	}
+
+	if checkpoint.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint version %d is not supported, expected %d", checkpoint.Version, checkpointVersion)
+	}
+
+	if checkpoint.Rules == nil {
+		return nil, fmt.Errorf("checkpoint has no rules")
+	}
+
+	if checkpoint.Step < 0 || checkpoint.Flashes < 0 {
+		return nil, fmt.Errorf("checkpoint step and flashes must not be negative")
+	}
+
+	return checkpoint, nil
+}
+
+// the simulation at the saved step, history only decides what is kept from here on
+func (checkpoint *Checkpoint) Resume(history HistoryOptions) (*FlashSimulation, error) {
+	initial, err := aocgrid.FromRows(checkpoint.Initial)
+	if err != nil {
+		return nil, fmt.Errorf("initial grid: %v", err)
+	}
+
+	current, err := aocgrid.FromRows(checkpoint.Grid)
+	if err != nil {
+		return nil, fmt.Errorf("grid: %v", err)
+	}
+
+	if initial.Rows() != current.Rows() || initial.Cols() != current.Cols() {
+		return nil, fmt.Errorf("grid is %dx%d, but the initial grid is %dx%d", current.Rows(), current.Cols(), initial.Rows(), initial.Cols())
+	}
+
+	return newFlashSimulation(&Grid{initial}, &Grid{current}, checkpoint.Step, checkpoint.Flashes, SimulationOptions{history, checkpoint.Rules})
+}
+
+func LoadSimulation(path string, history HistoryOptions) (*FlashSimulation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	checkpoint, err := ReadCheckpoint(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return checkpoint.Resume(history)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFileKeepsMode(t *testing.T) {
+	simulation, err := NewFlashSimulation("test_data2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	simulation.Simulate(10)
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	if err := simulation.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Fatalf("new checkpoint: %v, %v, expected mode 0644", info.Mode(), err)
+	}
+
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	simulation.Simulate(10)
+
+	if err := simulation.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("overwritten checkpoint: %v, %v, expected mode 0640", info.Mode(), err)
+	}
+
+	loaded, err := LoadSimulation(path, HistoryOptions{Mode: RetainNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Steps() != 20 || loaded.Flashes() != simulation.Flashes() || loaded.current.String() != simulation.current.String() {
+		t.Errorf("loaded step %d with %d flashes, saved step %d with %d", loaded.Steps(), loaded.Flashes(), simulation.Steps(), simulation.Flashes())
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNeverSynchronizes = errors.New("the grid runs into a cycle without ever flashing all at once")
+
+// a simulation up to its first repeated state, step Start+Length is the same grid as step
+// Start and everything after that repeats with period Length
+type Cycle struct {
+	Start   int
+	Length  int
+	initial *Grid
+	rules   *Rules
+	// flashes[i] are the flashes of steps 1..i
+	flashes []int
+	// the first step in which every cell flashed, -1 if there is none before the repeat
+	allFlash int
+}
+
+func sameCells(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// steps from initial until a grid repeats, an error when that takes more than maxSteps.
+// Brent's algorithm only keeps two grids while it looks, the flash counts of the steps
+// up to the end of the first cycle are only stored once the cycle is known
+func FindCycle(initial *Grid, rules *Rules, maxSteps int) (*Cycle, error) {
+	hare := NewEngine(initial, rules)
+	tortoise := append([]int{}, hare.front...)
+	power, length := 1, 1
+
+	hare.Step()
+
+	for steps := 1; !sameCells(tortoise, hare.front); steps++ {
+		if steps >= maxSteps {
+			return nil, fmt.Errorf("no repeated grid within %d steps", maxSteps)
+		}
+
+		if power == length {
+			tortoise = append(tortoise[:0], hare.front...)
+			power *= 2
+			length = 0
+		}
+
+		hare.Step()
+		length++
+	}
+
+	// a hare length steps ahead meets the tortoise right where the cycle starts
+	behind, ahead := NewEngine(initial, rules), NewEngine(initial, rules)
+	for i := 0; i < length; i++ {
+		ahead.Step()
+	}
+
+	start := 0
+	for !sameCells(behind.front, ahead.front) {
+		behind.Step()
+		ahead.Step()
+		start++
+	}
+
+	cycle := &Cycle{Start: start, Length: length, initial: initial, rules: rules, flashes: make([]int, 1, start+length+1), allFlash: -1}
+	engine := NewEngine(initial, rules)
+
+	for step := 1; step <= start+length; step++ {
+		flashes := engine.Step()
+		cycle.flashes = append(cycle.flashes, cycle.flashes[step-1]+flashes)
+
+		if engine.AllFlashed() && cycle.allFlash < 0 {
+			cycle.allFlash = step
+		}
+	}
+
+	return cycle, nil
+}
+
+// the step before or at the repeat that shows the same grid as step
+func (c *Cycle) reduce(step int) int {
+	if step <= c.Start+c.Length {
+		return step
+	}
+	return c.Start + (step-c.Start)%c.Length
+}
+
+// simulated again from the initial grid, up to Start+Length steps
+func (c *Cycle) At(step int) *Grid {
+	engine := NewEngine(c.initial, c.rules)
+
+	for i := c.reduce(step); i > 0; i-- {
+		engine.Step()
+	}
+
+	return engine.Grid()
+}
+
+// the flashes of steps 1..steps, the caller has to keep the total within an int
+func (c *Cycle) FlashesAfter(steps int) int {
+	if steps <= c.Start+c.Length {
+		return c.flashes[steps]
+	}
+
+	perCycle := c.flashes[c.Start+c.Length] - c.flashes[c.Start]
+	cycles := (steps - c.Start) / c.Length
+
+	return c.flashes[c.reduce(steps)] + cycles*perCycle
+}
+
+// a synchronised step has to show up before the grid repeats, otherwise it never does
+func (c *Cycle) FirstSynchronizedStep() (int, error) {
+	if c.allFlash < 0 {
+		return 0, ErrNeverSynchronizes
+	}
+	return c.allFlash, nil
+}
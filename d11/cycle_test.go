@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"aocgrid"
+)
+
+func TestFindCycleCountsFlashedCells(t *testing.T) {
+	// the first cell flashes twice through its own neighbourhood, the second not at all,
+	// which is as many flashes as cells without a synchronised step
+	rules := &Rules{
+		Threshold:     9,
+		Reset:         0,
+		Gain:          1,
+		FlashGain:     10,
+		Neighbourhood: []aocgrid.Point{{Row: 0, Col: 0}},
+		Edges:         EdgesBounded,
+		MaxFlashes:    2,
+	}
+
+	cells, err := aocgrid.FromRows([][]int{{9, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(&Grid{cells}, rules)
+	if flashes := engine.Step(); flashes != 2 || engine.AllFlashed() {
+		t.Fatalf("step 1 flashed %d times, all flashed %v, expected 2 flashes of one cell", flashes, engine.AllFlashed())
+	}
+
+	cycle, err := FindCycle(&Grid{cells}, rules, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cells stay one energy apart, so they never flash together
+	if step, err := cycle.FirstSynchronizedStep(); err != ErrNeverSynchronizes {
+		t.Errorf("first synchronised step %d, %v, expected ErrNeverSynchronizes", step, err)
+	}
+}
+
+func TestNilRulesArePuzzleRules(t *testing.T) {
+	simulation, err := NewFlashSimulationWithOptions("test_data2", SimulationOptions{DefaultHistoryOptions(), nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if flashes := simulation.Simulate(100); flashes != 1585 {
+		t.Errorf("%d flashes after 100 steps, expected 1585", flashes)
+	}
+}
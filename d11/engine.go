@@ -0,0 +1,126 @@
+package main
+
+import (
+	"aocgrid"
+)
+
+// steps a grid in place without allocating: front holds the current cells, the next step
+// is written to back and the two are swapped. The neighbours of every cell are worked out
+// once, and the flash stack can hold MaxFlashes of every cell, which is the most a step can flash
+type Engine struct {
+	rules *Rules
+	rows  int
+	cols  int
+	front []int
+	back  []int
+	// flashes of each cell in the current step, and how many cells flashed at all
+	flashed      []int32
+	flashedCells int
+	// the neighbours of cell i are neighbours[offsets[i]:offsets[i+1]]
+	neighbours []int32
+	offsets    []int32
+	stack      []int32
+}
+
+func NewEngine(grid *Grid, rules *Rules) *Engine {
+	cells := grid.cells
+	n := cells.Len()
+
+	e := &Engine{
+		rules:   rules,
+		rows:    cells.Rows(),
+		cols:    cells.Cols(),
+		front:   make([]int, 0, n),
+		back:    make([]int, n),
+		offsets: make([]int32, 0, n+1),
+		flashed: make([]int32, n),
+		stack:   make([]int32, 0, n*rules.MaxFlashes),
+	}
+
+	cells.Each(func(row, col, val int) {
+		e.front = append(e.front, val)
+		e.offsets = append(e.offsets, int32(len(e.neighbours)))
+
+		rules.neighbours(cells, row, col, func(nextRow, nextCol, _ int) {
+			e.neighbours = append(e.neighbours, int32(nextRow*e.cols+nextCol))
+		})
+	})
+	e.offsets = append(e.offsets, int32(len(e.neighbours)))
+
+	return e
+}
+
+// same as Grid.StepWith, returns the flashes of the step
+func (e *Engine) Step() int {
+	rules := e.rules
+	back := e.back
+	flashed := e.flashed
+	stack := e.stack[:0]
+	totalFlashes := 0
+	flashedCells := 0
+
+	for i, val := range e.front {
+		val += rules.Gain
+		flashed[i] = 0
+
+		if val > rules.Threshold {
+			totalFlashes++
+			flashedCells++
+			flashed[i] = 1
+			stack = append(stack, int32(i))
+			val = rules.Reset
+		}
+
+		back[i] = val
+	}
+
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, j := range e.neighbours[e.offsets[i]:e.offsets[i+1]] {
+			if flashed[j] >= int32(rules.MaxFlashes) {
+				continue
+			}
+
+			back[j] += rules.FlashGain
+
+			if back[j] > rules.Threshold {
+				totalFlashes++
+				if flashed[j] == 0 {
+					flashedCells++
+				}
+				flashed[j]++
+				stack = append(stack, j)
+				back[j] = rules.Reset
+			}
+		}
+	}
+
+	e.stack = stack
+	e.flashedCells = flashedCells
+	e.front, e.back = back, e.front
+
+	return totalFlashes
+}
+
+func (e *Engine) Len() int {
+	return len(e.front)
+}
+
+// whether every cell flashed in the last step, with MaxFlashes above 1 the flashes
+// of a step can add up to the number of cells without that
+func (e *Engine) AllFlashed() bool {
+	return e.flashedCells == len(e.front)
+}
+
+// a copy of the current cells
+func (e *Engine) Grid() *Grid {
+	cells := aocgrid.New[int](e.rows, e.cols)
+
+	for i, val := range e.front {
+		cells.Set(i/e.cols, i%e.cols, val)
+	}
+
+	return &Grid{cells}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"aocgrid"
+)
+
+func syntheticGrid(size int, seed int64) *Grid {
+	r := rand.New(rand.NewSource(seed))
+	cells := aocgrid.New[int](size, size)
+
+	cells.Each(func(row, col, _ int) {
+		cells.Set(row, col, r.Intn(10))
+	})
+
+	return &Grid{cells}
+}
+
+func testRules() []*Rules {
+	wrap := PuzzleRules()
+	wrap.Edges = EdgesWrap
+	wrap.Neighbourhood = aocgrid.Directions4
+
+	variant := &Rules{
+		Threshold:     5,
+		Reset:         1,
+		Gain:          2,
+		FlashGain:     3,
+		Neighbourhood: []aocgrid.Point{{Row: 0, Col: 1}, {Row: 2, Col: -1}},
+		Edges:         EdgesWrap,
+		MaxFlashes:    3,
+	}
+
+	return []*Rules{PuzzleRules(), wrap, variant}
+}
+
+func TestEngineMatchesStepWith(t *testing.T) {
+	input, err := readGridFromFile("test_data2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grids := []*Grid{{input}, syntheticGrid(1, 1), syntheticGrid(7, 2), syntheticGrid(40, 3)}
+
+	for _, rules := range testRules() {
+		for _, grid := range grids {
+			engine := NewEngine(grid, rules)
+			step := grid
+
+			for i := 1; i <= 500; i++ {
+				next, flashes := step.StepWith(rules, nil)
+
+				if engineFlashes := engine.Step(); engineFlashes != flashes {
+					t.Fatalf("step %d: Engine.Step flashed %d, Grid.StepWith %d", i, engineFlashes, flashes)
+				}
+
+				step = next
+			}
+
+			if engine.Grid().String() != step.String() {
+				t.Fatalf("grids differ after 500 steps:\n%s\n\n%s", engine.Grid(), step)
+			}
+		}
+	}
+}
+
+func TestEngineStepDoesNotAllocate(t *testing.T) {
+	engine := NewEngine(syntheticGrid(50, 1), PuzzleRules())
+
+	if allocs := testing.AllocsPerRun(1000, func() { engine.Step() }); allocs != 0 {
+		t.Errorf("Engine.Step allocates %v times per step", allocs)
+	}
+}
+
+func BenchmarkGridStep(b *testing.B) {
+	step := syntheticGrid(100, 1)
+	rules := PuzzleRules()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		step, _ = step.StepWith(rules, nil)
+	}
+}
+
+func BenchmarkEngineStep(b *testing.B) {
+	engine := NewEngine(syntheticGrid(100, 1), PuzzleRules())
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		engine.Step()
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"aocgrid"
+)
+
+type EventKind int
+
+const (
+	// a cell gained one energy, from the step itself or from a flashing neighbour
+	EventIncrement EventKind = iota
+	// a cell went over the threshold, flashed and was reset
+	EventFlash
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventIncrement:
+		return "increment"
+	case EventFlash:
+		return "flash"
+	}
+	return "unknown"
+}
+
+type Event struct {
+	Kind  EventKind
+	Point aocgrid.Point
+	// 0 for the increment every cell gets at the start of the step, wave n holds what the
+	// flashes of wave n-1 caused
+	Wave int
+	// the energy of the cell after the event
+	Energy int
+	// the flashing neighbour behind a cascade increment, the cell itself in wave 0
+	Source aocgrid.Point
+}
+
+func (e Event) String() string {
+	if e.Kind == EventIncrement && e.Wave > 0 {
+		return fmt.Sprintf("wave %d: %s %d,%d -> %d from %d,%d", e.Wave, e.Kind, e.Point.Row, e.Point.Col, e.Energy, e.Source.Row, e.Source.Col)
+	}
+	return fmt.Sprintf("wave %d: %s %d,%d -> %d", e.Wave, e.Kind, e.Point.Row, e.Point.Col, e.Energy)
+}
+
+// like Step, but calls visit for every event in the order it happened. The cascade is
+// run wave by wave, which flashes the same cells as any other order
+func (g Grid) StepEvents(visit func(Event)) (*Grid, int) {
+	return g.StepWith(PuzzleRules(), visit)
+}
+
+// one step under rules, visit may be nil
+func (g Grid) StepWith(rules *Rules, visit func(Event)) (*Grid, int) {
+	cells := g.cells.Copy()
+	flashed := aocgrid.New[int](cells.Rows(), cells.Cols())
+
+	wave := []aocgrid.Point{}
+	next := []aocgrid.Point{}
+	totalFlashes := 0
+
+	// adds gain to the cell and flashes it when it goes over the threshold
+	charge := func(point, source aocgrid.Point, n int, gain int) {
+		count := flashed.At(point.Row, point.Col)
+		if count >= rules.MaxFlashes {
+			return
+		}
+
+		energy := cells.At(point.Row, point.Col) + gain
+		cells.Set(point.Row, point.Col, energy)
+
+		if visit != nil {
+			visit(Event{EventIncrement, point, n, energy, source})
+		}
+
+		if energy > rules.Threshold {
+			totalFlashes += 1
+			next = append(next, point)
+			flashed.Set(point.Row, point.Col, count+1)
+			cells.Set(point.Row, point.Col, rules.Reset)
+
+			if visit != nil {
+				visit(Event{EventFlash, point, n, rules.Reset, source})
+			}
+		}
+	}
+
+	cells.Each(func(i, j, _ int) {
+		point := aocgrid.Point{Row: i, Col: j}
+		charge(point, point, 0, rules.Gain)
+	})
+
+	for n := 1; len(next) > 0; n++ {
+		wave, next = next, wave[:0]
+
+		for _, source := range wave {
+			rules.neighbours(cells, source.Row, source.Col, func(nextRow, nextCol, _ int) {
+				charge(aocgrid.Point{Row: nextRow, Col: nextCol}, source, n, rules.FlashGain)
+			})
+		}
+	}
+
+	return &Grid{cells}, totalFlashes
+}
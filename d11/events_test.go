@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"aocgrid"
+)
+
+func TestStepEventsOnPuzzleExample(t *testing.T) {
+	cells, err := aocgrid.ParseDigits([]string{"11111", "19991", "19191", "19991", "11111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := func(row, col int) aocgrid.Point { return aocgrid.Point{Row: row, Col: col} }
+
+	tests := []struct {
+		want       []string
+		increments []int
+		flashes    []Event
+	}{
+		// the eight 9s flash in wave 0 in row order and charge their unflashed neighbours in wave 1,
+		// where the centre flashes on the last of its eight increments
+		{
+			[]string{"34543", "40004", "50005", "40004", "34543"},
+			[]int{25, 6 + 4 + 6 + 4 + 4 + 6 + 4 + 6},
+			[]Event{
+				{EventFlash, p(1, 1), 0, 0, p(1, 1)},
+				{EventFlash, p(1, 2), 0, 0, p(1, 2)},
+				{EventFlash, p(1, 3), 0, 0, p(1, 3)},
+				{EventFlash, p(2, 1), 0, 0, p(2, 1)},
+				{EventFlash, p(2, 3), 0, 0, p(2, 3)},
+				{EventFlash, p(3, 1), 0, 0, p(3, 1)},
+				{EventFlash, p(3, 2), 0, 0, p(3, 2)},
+				{EventFlash, p(3, 3), 0, 0, p(3, 3)},
+				{EventFlash, p(2, 2), 1, 0, p(3, 3)},
+			},
+		},
+		{
+			[]string{"45654", "51115", "61116", "51115", "45654"},
+			[]int{25},
+			[]Event{},
+		},
+	}
+
+	grid := &Grid{cells}
+
+	for step, tt := range tests {
+		increments := []int{}
+		flashes := []Event{}
+
+		next, flashCount := grid.StepEvents(func(e Event) {
+			switch e.Kind {
+			case EventIncrement:
+				for len(increments) <= e.Wave {
+					increments = append(increments, 0)
+				}
+				increments[e.Wave]++
+			case EventFlash:
+				flashes = append(flashes, e)
+			}
+		})
+
+		if got := next.String(); got != strings.Join(tt.want, "\n") {
+			t.Errorf("step %d: grid\n%s\nexpected\n%s", step+1, got, strings.Join(tt.want, "\n"))
+		}
+		if flashCount != len(tt.flashes) {
+			t.Errorf("step %d: %d flashes, expected %d", step+1, flashCount, len(tt.flashes))
+		}
+		if len(increments) != len(tt.increments) {
+			t.Fatalf("step %d: increments per wave %v, expected %v", step+1, increments, tt.increments)
+		}
+		for wave := range tt.increments {
+			if increments[wave] != tt.increments[wave] {
+				t.Errorf("step %d: increments per wave %v, expected %v", step+1, increments, tt.increments)
+				break
+			}
+		}
+		if len(flashes) != len(tt.flashes) {
+			t.Fatalf("step %d: flashes %v, expected %v", step+1, flashes, tt.flashes)
+		}
+		for i := range tt.flashes {
+			if flashes[i] != tt.flashes[i] {
+				t.Errorf("step %d: flash %d is %v, expected %v", step+1, i, flashes[i], tt.flashes[i])
+			}
+		}
+
+		grid = next
+	}
+}
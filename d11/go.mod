@@ -1,3 +1,7 @@
 module aocd11
 
-go 1.17
+go 1.18
+
+require aocgrid v0.0.0
+
+replace aocgrid => ../grid
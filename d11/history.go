@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"aocgrid"
+)
+
+type RetentionMode int
+
+const (
+	// every step as a full grid
+	RetainAll RetentionMode = iota
+	// nothing but the initial and the current grid
+	RetainNone
+	// the last Keep steps
+	RetainLast
+	// every Keep-th step
+	RetainEvery
+	// every step as the change from the step before, with a full grid every Keep steps. Almost
+	// every cell changes, but most by the same amount, so a step is that amount and the cells
+	// that differ from it, which for the puzzle rules are the flashes and their neighbours
+	RetainDelta
+)
+
+func ParseRetentionMode(name string) (RetentionMode, error) {
+	switch name {
+	case "all":
+		return RetainAll, nil
+	case "none":
+		return RetainNone, nil
+	case "last":
+		return RetainLast, nil
+	case "every":
+		return RetainEvery, nil
+	case "delta":
+		return RetainDelta, nil
+	}
+	return RetainAll, fmt.Errorf("unknown history mode %q, expected all, none, last, every or delta", name)
+}
+
+type HistoryOptions struct {
+	Mode RetentionMode
+	Keep int
+}
+
+func DefaultHistoryOptions() HistoryOptions {
+	return HistoryOptions{Mode: RetainAll}
+}
+
+type snapshot struct {
+	step int
+	grid *Grid
+}
+
+// every cell moved by shift, except for the cells at indices, which became values
+type delta struct {
+	shift   int
+	indices []int32
+	values  []int
+}
+
+func (d delta) apply(cells *aocgrid.Grid[int]) {
+	cols := cells.Cols()
+
+	if d.shift != 0 {
+		cells.Each(func(row, col, val int) {
+			cells.Set(row, col, val+d.shift)
+		})
+	}
+
+	for i, index := range d.indices {
+		cells.Set(int(index)/cols, int(index)%cols, d.values[i])
+	}
+}
+
+// the retained steps of a simulation, steps are recorded in increasing order
+type History struct {
+	options   HistoryOptions
+	snapshots []snapshot
+	// RetainDelta only, deltas[i] turns step i-1 into step i, empty for the keyframes in snapshots
+	deltas   []delta
+	previous *Grid
+}
+
+func NewHistory(options HistoryOptions) (*History, error) {
+	if options.Mode != RetainAll && options.Mode != RetainNone && options.Keep < 1 {
+		return nil, fmt.Errorf("history keep must be at least 1, got %d", options.Keep)
+	}
+
+	return &History{options: options}, nil
+}
+
+// whether Record would keep anything of step
+func (h *History) Wants(step int) bool {
+	switch h.options.Mode {
+	case RetainNone:
+		return false
+	case RetainEvery:
+		return step%h.options.Keep == 0
+	}
+	return true
+}
+
+func (h *History) Record(step int, grid *Grid) {
+	switch h.options.Mode {
+	case RetainAll:
+		h.snapshots = append(h.snapshots, snapshot{step, grid})
+	case RetainLast:
+		h.snapshots = append(h.snapshots, snapshot{step, grid})
+		// drop the old steps in batches so the slice is only copied every Keep steps
+		if len(h.snapshots) >= 2*h.options.Keep {
+			h.snapshots = append([]snapshot{}, h.snapshots[len(h.snapshots)-h.options.Keep:]...)
+		}
+	case RetainEvery:
+		if step%h.options.Keep == 0 {
+			h.snapshots = append(h.snapshots, snapshot{step, grid})
+		}
+	case RetainDelta:
+		if step%h.options.Keep == 0 || h.previous == nil {
+			h.snapshots = append(h.snapshots, snapshot{step, grid})
+			h.deltas = append(h.deltas, delta{})
+		} else {
+			h.deltas = append(h.deltas, diffGrids(h.previous, grid))
+		}
+		h.previous = grid
+	}
+}
+
+// the shift is the change most cells share, found with a majority vote
+func diffGrids(before, after *Grid) delta {
+	shift, votes := 0, 0
+
+	after.cells.Each(func(row, col, val int) {
+		change := val - before.cells.At(row, col)
+
+		switch {
+		case votes == 0:
+			shift, votes = change, 1
+		case change == shift:
+			votes++
+		default:
+			votes--
+		}
+	})
+
+	d := delta{shift: shift}
+	cols := after.cells.Cols()
+
+	after.cells.Each(func(row, col, val int) {
+		if before.cells.At(row, col)+shift != val {
+			d.indices = append(d.indices, int32(row*cols+col))
+			d.values = append(d.values, val)
+		}
+	})
+
+	return d
+}
+
+// the grid after step, false when the step was not retained
+func (h *History) At(step int) (*Grid, bool) {
+	if h.options.Mode == RetainDelta {
+		return h.deltaAt(step)
+	}
+
+	first := h.first()
+	i := sort.Search(len(h.snapshots), func(i int) bool { return h.snapshots[i].step >= step })
+
+	if i < first || i == len(h.snapshots) || h.snapshots[i].step != step {
+		return nil, false
+	}
+
+	return h.snapshots[i].grid, true
+}
+
+func (h *History) deltaAt(step int) (*Grid, bool) {
+	if len(h.snapshots) == 0 || step < h.snapshots[0].step || step-h.snapshots[0].step >= len(h.deltas) {
+		return nil, false
+	}
+
+	i := sort.Search(len(h.snapshots), func(i int) bool { return h.snapshots[i].step > step }) - 1
+	keyframe := h.snapshots[i]
+
+	if keyframe.step == step {
+		return keyframe.grid, true
+	}
+
+	cells := keyframe.grid.cells.Copy()
+	base := h.snapshots[0].step
+
+	for s := keyframe.step + 1; s <= step; s++ {
+		h.deltas[s-base].apply(cells)
+	}
+
+	return &Grid{cells}, true
+}
+
+// the first snapshot that is still retained, RetainLast keeps up to 2*Keep-1 around
+func (h *History) first() int {
+	if h.options.Mode == RetainLast && len(h.snapshots) > h.options.Keep {
+		return len(h.snapshots) - h.options.Keep
+	}
+	return 0
+}
+
+// the retained steps in increasing order
+func (h *History) Steps() []int {
+	steps := []int{}
+
+	if h.options.Mode == RetainDelta {
+		if len(h.snapshots) > 0 {
+			for i := range h.deltas {
+				steps = append(steps, h.snapshots[0].step+i)
+			}
+		}
+		return steps
+	}
+
+	for _, s := range h.snapshots[h.first():] {
+		steps = append(steps, s.step)
+	}
+
+	return steps
+}
+
+// the step and grid of every retained step in order, delta steps are rebuilt one after another
+func (h *History) Each(visit func(step int, grid *Grid)) {
+	if h.options.Mode != RetainDelta {
+		for _, s := range h.snapshots[h.first():] {
+			visit(s.step, s.grid)
+		}
+		return
+	}
+
+	if len(h.snapshots) == 0 {
+		return
+	}
+
+	var cells *aocgrid.Grid[int]
+	next := 0
+
+	for i, d := range h.deltas {
+		step := h.snapshots[0].step + i
+
+		if next < len(h.snapshots) && h.snapshots[next].step == step {
+			cells = h.snapshots[next].grid.cells.Copy()
+			next++
+		} else {
+			d.apply(cells)
+		}
+
+		visit(step, &Grid{cells.Copy()})
+	}
+}
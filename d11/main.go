@@ -2,11 +2,14 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
+
+	"aocgrid"
 )
 
 func readLinesFromFile(filename string) ([]string, error) {
@@ -30,203 +33,420 @@ func readLinesFromFile(filename string) ([]string, error) {
 	return lines, nil
 }
 
-func transformLinesToGrid(lines []string) ([][]int, error) {
-	grid := [][]int{}
+func readGridFromFile(filename string) (*aocgrid.Grid[int], error) {
+	lines, err := readLinesFromFile(filename)
 
-	for _, line := range lines {
-		values := strings.Split(line, "")
-		row := []int{}
+	if err != nil {
+		return nil, err
+	}
 
-		for _, value := range values {
-			valueInt, err := strconv.Atoi(value)
+	cells, err := aocgrid.ParseDigits(lines)
 
-			if err != nil {
-				return nil, err
-			}
+	if err != nil {
+		return nil, err
+	}
 
-			row = append(row, valueInt)
-		}
+	return cells, nil
+}
 
-		grid = append(grid, row)
-	}
+type Grid struct {
+	cells *aocgrid.Grid[int]
+}
 
-	return grid, nil
+func (g Grid) String() string {
+	return g.cells.String()
 }
 
-func readGridFromFile(filename string) ([][]int, error) {
-	lines, err := readLinesFromFile(filename)
+func (g Grid) Step() (*Grid, int) {
+	return g.StepEvents(nil)
+}
 
+type FlashSimulation struct {
+	initial *Grid
+	current *Grid
+	step    int
+	// flashes of all steps so far
+	flashes int
+	rules   *Rules
+	history *History
+}
+
+// only the retained steps are printed
+func (simulation FlashSimulation) Print() {
+	simulation.history.Each(func(_ int, grid *Grid) {
+		fmt.Println(grid.String())
+		fmt.Println()
+	})
+}
+
+type SimulationOptions struct {
+	History HistoryOptions
+	// nil means the puzzle rules
+	Rules *Rules
+}
+
+func DefaultSimulationOptions() SimulationOptions {
+	return SimulationOptions{DefaultHistoryOptions(), PuzzleRules()}
+}
+
+func NewFlashSimulation(filename string) (*FlashSimulation, error) {
+	return NewFlashSimulationWithOptions(filename, DefaultSimulationOptions())
+}
+
+func NewFlashSimulationWithOptions(filename string, options SimulationOptions) (*FlashSimulation, error) {
+	cells, err := readGridFromFile(filename)
 	if err != nil {
 		return nil, err
 	}
 
-	grid, err := transformLinesToGrid(lines)
+	initial := &Grid{cells}
+
+	return newFlashSimulation(initial, initial, 0, 0, options)
+}
+
+// the history starts at step, the steps before it are not known
+func newFlashSimulation(initial, current *Grid, step int, flashes int, options SimulationOptions) (*FlashSimulation, error) {
+	if options.Rules == nil {
+		options.Rules = PuzzleRules()
+	}
+
+	if err := options.Rules.Validate(); err != nil {
+		return nil, err
+	}
 
+	history, err := NewHistory(options.History)
 	if err != nil {
 		return nil, err
 	}
 
-	return grid, nil
-}
+	history.Record(step, current)
 
-type Grid struct {
-	data [][]int
+	return &FlashSimulation{initial, current, step, flashes, options.Rules, history}, nil
 }
 
-func (g Grid) String() string {
-	lines := []string{}
+func (simulation *FlashSimulation) Rules() *Rules {
+	return simulation.rules
+}
 
-	for _, row := range g.data {
-		line := ""
+// the grid after step, the initial and current grid are always there, other steps only
+// when the history kept them
+func (simulation *FlashSimulation) At(step int) (*Grid, bool) {
+	switch step {
+	case 0:
+		return simulation.initial, true
+	case simulation.step:
+		return simulation.current, true
+	}
+	return simulation.history.At(step)
+}
 
-		for _, val := range row {
-			valStr := strconv.Itoa(val)
-			line += valStr
-		}
+func (simulation *FlashSimulation) History() *History {
+	return simulation.history
+}
 
-		lines = append(lines, line)
-	}
+// the number of steps simulated so far
+func (simulation *FlashSimulation) Steps() int {
+	return simulation.step
+}
 
-	return strings.Join(lines, "\n")
+func (simulation *FlashSimulation) Flashes() int {
+	return simulation.flashes
 }
 
-func (g Grid) DataCopy() [][]int {
-	rows := len(g.data)
-	cols := len(g.data[0])
+// runs steps more steps from the current grid and returns their flashes, grids are only
+// built for the steps the history keeps
+func (simulation *FlashSimulation) Simulate(steps int) int {
+	engine := NewEngine(simulation.current, simulation.rules)
+	totalFlashes := 0
 
-	copy := [][]int{}
+	for i := 0; i < steps; i++ {
+		totalFlashes += engine.Step()
+		simulation.step++
 
-	for i := 0; i < rows; i++ {
-		row := []int{}
-		for j := 0; j < cols; j++ {
-			row = append(row, g.data[i][j])
+		if simulation.history.Wants(simulation.step) {
+			simulation.history.Record(simulation.step, engine.Grid())
 		}
-		copy = append(copy, row)
 	}
 
-	return copy
+	simulation.current = engine.Grid()
+	simulation.flashes += totalFlashes
+
+	return totalFlashes
 }
 
-func (g Grid) Step() (*Grid, int) {
-	dataCopy := g.DataCopy()
+// the first step from the initial grid where every cell flashes, ErrNeverSynchronizes
+// when the grid repeats before that
+func (simulation *FlashSimulation) FirstStepWithAllFlash() (int, error) {
+	cycle, err := FindCycle(simulation.initial, simulation.rules, maxCycleSteps)
+	if err != nil {
+		return 0, err
+	}
 
-	directions := [8][2]int{{0, 1}, {1, 0}, {0, -1}, {-1, 0}, {1, 1}, {-1, -1}, {1, -1}, {-1, 1}}
-	queue := [][2]int{}
-	totalFlashes := 0
+	return cycle.FirstSynchronizedStep()
+}
 
-	for i := 0; i < len(dataCopy); i++ {
-		for j := 0; j < len(dataCopy[i]); j++ {
-			dataCopy[i][j] += 1
+// how long FindCycle may look for a repeated grid before giving up
+const maxCycleSteps = 10000000
 
-			if dataCopy[i][j] > 9 {
-				totalFlashes += 1
-				queue = append(queue, [2]int{i, j})
-				dataCopy[i][j] = 0
-			}
-		}
+func writeGIF(path string, initial *Grid, rules *Rules, steps int, scale int, fps float64) error {
+	if steps <= 0 {
+		return fmt.Errorf("a gif needs a positive number of frames, got %d", steps)
 	}
 
-	for len(queue) > 0 {
-		coord := queue[0]
-		queue = queue[1:]
-		row := coord[0]
-		col := coord[1]
+	frames := []Frame{FirstFrame(initial)}
+	for i := 0; i < steps; i++ {
+		frames = append(frames, frames[i].Next(rules))
+	}
 
-		for _, dir := range directions {
-			nextRow := row + dir[0]
-			nextCol := col + dir[1]
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
 
-			if nextRow < 0 || nextRow == len(dataCopy) {
-				continue
-			}
+	// gif delays are in hundredths of a second
+	delay := 10
+	if fps > 0 {
+		delay = int(100 / fps)
+	}
 
-			if nextCol < 0 || nextCol == len(dataCopy[0]) {
-				continue
-			}
+	if err := WriteGIF(f, frames, rules, scale, delay); err != nil {
+		f.Close()
+		return err
+	}
 
-			if dataCopy[nextRow][nextCol] == 0 {
-				continue
-			}
+	return f.Close()
+}
 
-			dataCopy[nextRow][nextCol] += 1
+// the puzzle rules or the rules file, with neighbours 4 or 8 and wrap on top, neighbours 0 keeps them
+func rulesFromFlags(path string, neighbours int, wrap bool) (*Rules, error) {
+	rules := PuzzleRules()
 
-			if dataCopy[nextRow][nextCol] > 9 {
-				totalFlashes += 1
-				queue = append(queue, [2]int{nextRow, nextCol})
-				dataCopy[nextRow][nextCol] = 0
-			}
+	if path != "" {
+		var err error
+		if rules, err = LoadRules(path); err != nil {
+			return nil, err
 		}
 	}
 
-	return &Grid{dataCopy}, totalFlashes
-}
+	switch neighbours {
+	case 0:
+	case 4:
+		rules.Neighbourhood = aocgrid.Directions4
+	case 8:
+		rules.Neighbourhood = aocgrid.Directions8
+	default:
+		return nil, fmt.Errorf("neighbours must be 4 or 8, got %d", neighbours)
+	}
 
-type FlashSimulation struct {
-	steps []*Grid
+	if wrap {
+		rules.Edges = EdgesWrap
+	}
+
+	return rules, nil
 }
 
-func (simulation FlashSimulation) Print() {
-	for _, step := range simulation.steps {
-		fmt.Println(step.String())
-		fmt.Println()
+// go run . save [-steps n] [-rules file] [-neighbours 4|8] [-wrap] -o checkpoint [input] starts a
+// simulation and saves it after n steps
+func runSave(args []string) error {
+	flags := flag.NewFlagSet("save", flag.ExitOnError)
+	steps := flags.Int("steps", 0, "steps to simulate before saving")
+	out := flags.String("o", "", "checkpoint file to write")
+	rulesPath := flags.String("rules", "", "json file with the rules, missing fields keep the puzzle rules")
+	neighbours := flags.Int("neighbours", 0, "4 or 8 neighbours, overrides the rules")
+	wrap := flags.Bool("wrap", false, "neighbours past an edge come from the opposite edge, overrides the rules")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("save needs a checkpoint file, set -o")
 	}
-}
 
-func NewFlashSimulation(filename string) (*FlashSimulation, error) {
-	grid, err := readGridFromFile(filename)
+	filename := "test_data2"
+	if flags.NArg() > 0 {
+		filename = flags.Arg(0)
+	}
+
+	rules, err := rulesFromFlags(*rulesPath, *neighbours, *wrap)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	simulation, err := NewFlashSimulationWithOptions(filename, SimulationOptions{HistoryOptions{Mode: RetainNone}, rules})
+	if err != nil {
+		return err
 	}
-	steps := []*Grid{{grid}}
-	return &FlashSimulation{steps}, nil
+
+	simulation.Simulate(*steps)
+	printStatus(simulation)
+
+	return simulation.SaveFile(*out)
 }
 
-func (simulation *FlashSimulation) Simulate(steps int) int {
-	step := simulation.steps[0]
-	totalFlashes := 0
+// go run . status checkpoint prints where a saved simulation stands, continue runs it further
+func runStatus(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	flags.Parse(args)
 
-	for i := 0; i < steps; i++ {
-		s, flashesFromStep := step.Step()
-		simulation.steps = append(simulation.steps, s)
-		totalFlashes += flashesFromStep
-		step = s
+	if flags.NArg() != 1 {
+		return fmt.Errorf("status needs exactly one checkpoint file")
 	}
 
-	return totalFlashes
-}
+	simulation, err := LoadSimulation(flags.Arg(0), HistoryOptions{Mode: RetainNone})
+	if err != nil {
+		return err
+	}
 
-func (simulation *FlashSimulation) FirstStepWithAllFlash() int {
-	step := simulation.steps[0]
+	printStatus(simulation)
+	fmt.Println(simulation.current)
 
-	rows := len(step.data)
-	cols := len(step.data[0])
-	targetFlashes := rows * cols
+	return nil
+}
 
-	i := 1
+// go run . continue [-steps n] [-o checkpoint] checkpoint runs n more steps and saves them,
+// back to the same file unless -o is given
+func runContinue(args []string) error {
+	flags := flag.NewFlagSet("continue", flag.ExitOnError)
+	steps := flags.Int("steps", 100, "steps to simulate before saving again")
+	out := flags.String("o", "", "checkpoint file to write instead of the one read")
+	flags.Parse(args)
 
-	for {
-		s, flashesFromStep := step.Step()
+	if flags.NArg() != 1 {
+		return fmt.Errorf("continue needs exactly one checkpoint file")
+	}
 
-		if flashesFromStep == targetFlashes {
-			return i
-		}
+	path := flags.Arg(0)
+	if *out == "" {
+		*out = path
+	}
 
-		step = s
-		i += 1
+	simulation, err := LoadSimulation(path, HistoryOptions{Mode: RetainNone})
+	if err != nil {
+		return err
 	}
+
+	simulation.Simulate(*steps)
+	printStatus(simulation)
+
+	return simulation.SaveFile(*out)
+}
+
+func printStatus(simulation *FlashSimulation) {
+	fmt.Printf("step %d, %d flashes\n", simulation.Steps(), simulation.Flashes())
 }
 
 func main() {
+	commands := map[string]func([]string) error{"save": runSave, "status": runStatus, "continue": runContinue}
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	events := flag.Int("events", 0, "print the events of the first n steps")
+	historyMode := flag.String("history", "all", "steps to keep: all, none, last, every or delta")
+	keep := flag.Int("keep", 100, "steps kept by -history last, the interval of every and the keyframe interval of delta")
+	printSteps := flag.Bool("print", false, "print the kept steps of part 1")
+	after := flag.Int("after", 0, "print the total flashes after n steps, found through the cycle of the grid")
+	rulesPath := flag.String("rules", "", "json file with the rules, missing fields keep the puzzle rules")
+	neighbours := flag.Int("neighbours", 0, "4 or 8 neighbours, overrides the rules")
+	wrap := flag.Bool("wrap", false, "neighbours past an edge come from the opposite edge, overrides the rules")
+	play := flag.Bool("play", false, "animate the simulation in the terminal")
+	fps := flag.Float64("fps", 10, "frames per second for -play")
+	frames := flag.Int("frames", 100, "steps shown by -play and -gif, 0 plays until quit")
+	gifOut := flag.String("gif", "", "write the first -frames steps as an animated gif to this file")
+	scale := flag.Int("scale", 16, "pixels per cell in the gif")
+	flag.Parse()
+
 	filename := "test_data2"
-	simulation, err := NewFlashSimulation(filename)
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
+	}
+
+	mode, err := ParseRetentionMode(*historyMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rules, err := rulesFromFlags(*rulesPath, *neighbours, *wrap)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	simulation, err := NewFlashSimulationWithOptions(filename, SimulationOptions{HistoryOptions{mode, *keep}, rules})
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if *gifOut != "" {
+		if err := writeGIF(*gifOut, simulation.initial, rules, *frames, *scale, *fps); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *play {
+		// caught before the terminal changes, so Ctrl-C always finds a handler that restores it
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+
+		restore, err := cbreak()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "keys need enter, the terminal could not be switched to single keys:", err)
+		} else {
+			defer restore()
+		}
+
+		NewPlayer(rules, PlayerOptions{*fps, *frames}, os.Stdin, os.Stdout).Play(FirstFrame(simulation.initial), signals)
+		return
+	}
+
+	if *after > 0 {
+		cycle, err := FindCycle(simulation.initial, rules, maxCycleSteps)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("cycle of length %d from step %d\n", cycle.Length, cycle.Start)
+		fmt.Println(cycle.FlashesAfter(*after))
+
+		if step, err := cycle.FirstSynchronizedStep(); err != nil {
+			fmt.Println(err)
+		} else {
+			fmt.Printf("first synchronised step %d\n", step)
+		}
+		return
+	}
+
+	if *events > 0 {
+		step := simulation.initial
+
+		for i := 1; i <= *events; i++ {
+			fmt.Printf("step %d\n", i)
+			step, _ = step.StepWith(rules, func(e Event) {
+				fmt.Println(e)
+			})
+			fmt.Println(step)
+		}
+		return
+	}
+
 	flashes := simulation.Simulate(100)
+
+	if *printSteps {
+		simulation.Print()
+	}
+
 	fmt.Println(flashes)
 
-	step := simulation.FirstStepWithAllFlash()
+	step, err := simulation.FirstStepWithAllFlash()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	fmt.Println(step)
 }
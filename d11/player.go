@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"aocgrid"
+)
+
+type Frame struct {
+	Step    int
+	Grid    *Grid
+	Flashes int
+	// the cells that flashed during the step
+	Flashed *aocgrid.Grid[bool]
+}
+
+func FirstFrame(grid *Grid) Frame {
+	return Frame{0, grid, 0, aocgrid.New[bool](grid.cells.Rows(), grid.cells.Cols())}
+}
+
+func (f Frame) Next(rules *Rules) Frame {
+	flashed := aocgrid.New[bool](f.Grid.cells.Rows(), f.Grid.cells.Cols())
+
+	grid, flashes := f.Grid.StepWith(rules, func(e Event) {
+		if e.Kind == EventFlash {
+			flashed.Set(e.Point.Row, e.Point.Col, true)
+		}
+	})
+
+	return Frame{f.Step + 1, grid, flashes, flashed}
+}
+
+var flashColor = color.RGBA{255, 255, 255, 255}
+
+// dark blue for no energy up to orange at the threshold
+func energyColor(energy int, rules *Rules) color.RGBA {
+	t := 1.0
+	if rules.Threshold > 0 {
+		t = float64(energy) / float64(rules.Threshold)
+	}
+	if t < 0 {
+		t = 0
+	}
+	if t > 1 {
+		t = 1
+	}
+
+	return color.RGBA{uint8(20 + 235*t), uint8(20 + 140*t), uint8(60 * (1 - t)), 255}
+}
+
+// one digit per cell on its energy colour, flashed cells are bold on white
+func (f Frame) ANSI(rules *Rules) string {
+	var out strings.Builder
+
+	cells := f.Grid.cells
+
+	for row := 0; row < cells.Rows(); row++ {
+		for col := 0; col < cells.Cols(); col++ {
+			energy := cells.At(row, col)
+
+			ch := "#"
+			if energy >= 0 && energy <= 9 {
+				ch = fmt.Sprint(energy)
+			}
+
+			if f.Flashed.At(row, col) {
+				fmt.Fprintf(&out, "\x1b[48;2;255;255;255m\x1b[38;2;0;0;0m\x1b[1m%s\x1b[22m", ch)
+				continue
+			}
+
+			c := energyColor(energy, rules)
+			fmt.Fprintf(&out, "\x1b[48;2;%d;%d;%dm\x1b[38;2;220;220;220m%s", c.R, c.G, c.B, ch)
+		}
+		out.WriteString("\x1b[0m\n")
+	}
+
+	return out.String()
+}
+
+type PlayerOptions struct {
+	FPS float64
+	// stop after this many steps, 0 plays until quit
+	Steps int
+}
+
+// redraws the simulation in place, keys read from in: space pauses and resumes,
+// n steps once while paused, + and - change the speed and q quits
+type Player struct {
+	rules   *Rules
+	options PlayerOptions
+	in      io.Reader
+	out     io.Writer
+}
+
+func NewPlayer(rules *Rules, options PlayerOptions, in io.Reader, out io.Writer) *Player {
+	if options.FPS <= 0 {
+		options.FPS = 10
+	}
+	return &Player{rules, options, in, out}
+}
+
+func (p *Player) draw(frame Frame, paused bool) {
+	state := fmt.Sprintf("%.1f fps", p.options.FPS)
+	if paused {
+		state = "paused"
+	}
+
+	fmt.Fprintf(p.out, "\x1b[H\x1b[2Kstep %d, %d flashes, %s\n", frame.Step, frame.Flashes, state)
+	fmt.Fprint(p.out, frame.ANSI(p.rules))
+	fmt.Fprint(p.out, "\x1b[2K[space] pause  [n] step  [+/-] speed  [q] quit\n")
+}
+
+// stop ends the play like q does, so the cursor is shown again, main passes SIGINT and SIGTERM
+// there to get the terminal back after Ctrl-C. A nil stop never fires
+func (p *Player) Play(start Frame, stop <-chan os.Signal) {
+	keys := make(chan byte)
+
+	go func() {
+		in := bufio.NewReader(p.in)
+		for {
+			key, err := in.ReadByte()
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- key
+		}
+	}()
+
+	fmt.Fprint(p.out, "\x1b[2J\x1b[?25l")
+	defer fmt.Fprint(p.out, "\x1b[?25h")
+
+	frame := start
+	paused := false
+	p.draw(frame, paused)
+
+	timer := time.NewTimer(p.interval())
+	defer timer.Stop()
+
+	for p.options.Steps == 0 || frame.Step < p.options.Steps {
+		advance := false
+
+		select {
+		case <-stop:
+			return
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+
+			switch key {
+			case 'q':
+				return
+			case ' ':
+				paused = !paused
+				timer.Reset(p.interval())
+			case 'n':
+				advance = paused
+			case '+':
+				p.options.FPS *= 2
+			case '-':
+				p.options.FPS /= 2
+			}
+		case <-timer.C:
+			advance = !paused
+			timer.Reset(p.interval())
+		}
+
+		if advance {
+			frame = frame.Next(p.rules)
+		}
+
+		p.draw(frame, paused)
+	}
+}
+
+func (p *Player) interval() time.Duration {
+	return time.Duration(float64(time.Second) / p.options.FPS)
+}
+
+// switches the terminal to reading single keys without echo, stty is all the standard
+// library has for that. The returned function restores the old settings
+func cbreak() (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := stty("cbreak", "-echo"); err != nil {
+		return nil, err
+	}
+
+	return func() { stty(state) }, nil
+}
+
+// the palette is the flash colour and up to 255 energy shades between 0 and the threshold
+func WriteGIF(w io.Writer, frames []Frame, rules *Rules, scale int, delay int) error {
+	if scale < 1 {
+		scale = 1
+	}
+
+	shades := rules.Threshold + 1
+	if shades > 255 {
+		shades = 255
+	}
+
+	palette := color.Palette{flashColor}
+	for i := 0; i < shades; i++ {
+		palette = append(palette, energyColor(rules.Threshold*i/max(shades-1, 1), rules))
+	}
+
+	animation := &gif.GIF{}
+
+	for _, frame := range frames {
+		cells := frame.Grid.cells
+		img := image.NewPaletted(image.Rect(0, 0, cells.Cols()*scale, cells.Rows()*scale), palette)
+
+		cells.Each(func(row, col, energy int) {
+			index := uint8(0)
+			if !frame.Flashed.At(row, col) {
+				shade := 0
+				if rules.Threshold > 0 {
+					shade = energy * (shades - 1) / rules.Threshold
+				}
+				index = uint8(1 + min(max(shade, 0), shades-1))
+			}
+
+			for y := 0; y < scale; y++ {
+				for x := 0; x < scale; x++ {
+					img.SetColorIndex(col*scale+x, row*scale+y, index)
+				}
+			}
+		})
+
+		animation.Image = append(animation.Image, img)
+		animation.Delay = append(animation.Delay, delay)
+	}
+
+	return gif.EncodeAll(w, animation)
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
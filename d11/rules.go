@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"aocgrid"
+)
+
+type Edges int
+
+const (
+	// cells on an edge have fewer neighbours
+	EdgesBounded Edges = iota
+	// neighbours past an edge are taken from the opposite edge
+	EdgesWrap
+)
+
+func (e Edges) String() string {
+	switch e {
+	case EdgesBounded:
+		return "bounded"
+	case EdgesWrap:
+		return "wrap"
+	}
+	return "unknown"
+}
+
+func ParseEdges(name string) (Edges, error) {
+	switch name {
+	case "bounded":
+		return EdgesBounded, nil
+	case "wrap":
+		return EdgesWrap, nil
+	}
+	return EdgesBounded, fmt.Errorf("unknown edges %q, expected bounded or wrap", name)
+}
+
+func (e Edges) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+func (e *Edges) UnmarshalText(text []byte) error {
+	edges, err := ParseEdges(string(text))
+	*e = edges
+	return err
+}
+
+type Rules struct {
+	// a cell flashes when its energy goes over Threshold
+	Threshold int `json:"threshold"`
+	// the energy of a cell right after it flashed
+	Reset int `json:"reset"`
+	// the energy every cell gains at the start of a step
+	Gain int `json:"gain"`
+	// the energy a flash gives each of its neighbours
+	FlashGain     int             `json:"flash_gain"`
+	Neighbourhood []aocgrid.Point `json:"neighbourhood"`
+	Edges         Edges           `json:"edges"`
+	// how often a cell may flash in one step, once it has used them up it keeps its reset
+	// energy until the next step
+	MaxFlashes int `json:"max_flashes"`
+}
+
+func PuzzleRules() *Rules {
+	return &Rules{
+		Threshold:     9,
+		Reset:         0,
+		Gain:          1,
+		FlashGain:     1,
+		Neighbourhood: aocgrid.Directions8,
+		Edges:         EdgesBounded,
+		MaxFlashes:    1,
+	}
+}
+
+// starts from the puzzle rules, so a file only needs the fields it changes
+func ParseRules(r io.Reader) (*Rules, error) {
+	rules := PuzzleRules()
+
+	if err := json.NewDecoder(r).Decode(rules); err != nil {
+		return nil, err
+	}
+
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func LoadRules(path string) (*Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f)
+}
+
+// MaxFlashes keeps every step finite, a cell can not flash more often than that
+func (r *Rules) Validate() error {
+	if r.Reset > r.Threshold {
+		return fmt.Errorf("reset %d is over the threshold %d, cells would flash again right away", r.Reset, r.Threshold)
+	}
+
+	if r.Gain < 0 || r.FlashGain < 0 {
+		return fmt.Errorf("gains must not be negative")
+	}
+
+	if len(r.Neighbourhood) == 0 {
+		return fmt.Errorf("neighbourhood is empty")
+	}
+
+	if r.MaxFlashes < 1 {
+		return fmt.Errorf("max flashes must be at least 1, got %d", r.MaxFlashes)
+	}
+
+	return nil
+}
+
+func (r *Rules) neighbours(cells *aocgrid.Grid[int], row, col int, visit func(row, col, val int)) {
+	if r.Edges == EdgesWrap {
+		cells.NeighboursWrap(row, col, r.Neighbourhood, visit)
+	} else {
+		cells.Neighbours(row, col, r.Neighbourhood, visit)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import "sync"
+
+func sign(a int) int {
+	if a > 0 {
+		return 1
+	}
+	if a < 0 {
+		return -1
+	}
+	return 0
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// same cells as Walk, but only the ones with lo <= row < hi
+func (l Line) WalkRows(lo, hi int, visit func(row, col int)) {
+	stepRow := sign(l.to.y - l.from.y)
+	stepCol := sign(l.to.x - l.from.x)
+	length := max(abs(l.to.y-l.from.y), abs(l.to.x-l.from.x))
+
+	first, last := 0, length
+
+	switch stepRow {
+	case 0:
+		if l.from.y < lo || l.from.y >= hi {
+			return
+		}
+	case 1:
+		first = max(first, lo-l.from.y)
+		last = min(last, hi-1-l.from.y)
+	case -1:
+		first = max(first, l.from.y-(hi-1))
+		last = min(last, l.from.y-lo)
+	}
+
+	for t := first; t <= last; t++ {
+		visit(l.from.y+stepRow*t, l.from.x+stepCol*t)
+	}
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// every worker owns a horizontal stripe of the layout and draws the part of each line
+// that falls into it, so no two workers ever touch the same cell
+func NewHydrothermalVentMapWithWorkers(lines []*Line, rows int, cols int, workers int) *HydrothermalVentMap {
+	if workers <= 1 {
+		return NewHydrothermalVentMap(lines, rows, cols)
+	}
+
+	layout := initLayout(rows, cols)
+	stripe := (len(layout) + workers - 1) / workers
+	counts := make([]int, workers)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		lo := w * stripe
+		hi := min(lo+stripe, len(layout))
+
+		if lo >= hi {
+			break
+		}
+
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+
+			overlappingVents := 0
+
+			for _, line := range lines {
+				line.WalkRows(lo, hi, func(row, col int) {
+					layout[row][col] += 1
+
+					if layout[row][col] == 2 {
+						overlappingVents += 1
+					}
+				})
+			}
+
+			counts[w] = overlappingVents
+		}(w, lo, hi)
+	}
+
+	wg.Wait()
+
+	overlappingVents := 0
+	for _, count := range counts {
+		overlappingVents += count
+	}
+
+	ventmap := &HydrothermalVentMap{overlappingVents, layout, map[lineKey]int{}}
+
+	for _, line := range lines {
+		ventmap.lines[keyOf(line)] += 1
+	}
+
+	return ventmap
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// horizontal, vertical and 45 degree lines only, like the puzzle input
+func syntheticLines(n int, size int, seed int64) []*Line {
+	r := rand.New(rand.NewSource(seed))
+	lines := make([]*Line, 0, n)
+
+	for i := 0; i < n; i++ {
+		x1 := r.Intn(size)
+		y1 := r.Intn(size)
+		length := r.Intn(size / 10)
+
+		x2, y2 := x1, y1
+
+		switch r.Intn(3) {
+		case 0:
+			x2 = min(size-1, x1+length)
+		case 1:
+			y2 = min(size-1, y1+length)
+		default:
+			length = min(length, min(size-1-x1, size-1-y1))
+			x2 = x1 + length
+			y2 = y1 + length
+		}
+
+		lines = append(lines, &Line{&Point{x1, y1}, &Point{x2, y2}})
+	}
+
+	return lines
+}
+
+func TestConcurrentMatchesSequential(t *testing.T) {
+	for _, size := range []int{10, 100, 1000} {
+		lines := syntheticLines(5000, size, int64(size))
+		sequential := NewHydrothermalVentMap(lines, size, size)
+
+		for _, workers := range []int{0, 1, 2, 3, 7, 64, size + 1} {
+			concurrent := NewHydrothermalVentMapWithWorkers(lines, size, size, workers)
+
+			if concurrent.OverlappingVents() != sequential.OverlappingVents() {
+				t.Errorf("size %d, %d workers: %d overlapping vents, sequential %d", size, workers, concurrent.OverlappingVents(), sequential.OverlappingVents())
+			}
+
+			for row := range sequential.layout {
+				for col := range sequential.layout[row] {
+					if concurrent.layout[row][col] != sequential.layout[row][col] {
+						t.Fatalf("size %d, %d workers: cell %d,%d is %d, sequential %d", size, workers, row, col, concurrent.layout[row][col], sequential.layout[row][col])
+					}
+				}
+			}
+		}
+	}
+}
+
+// 2M lines keep the rasterisation, not the goroutine setup, dominating the timings
+const benchmarkLines = 2000000
+
+func BenchmarkSequential(b *testing.B) {
+	lines := syntheticLines(benchmarkLines, 1000, 1)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		NewHydrothermalVentMap(lines, 1000, 1000)
+	}
+}
+
+func BenchmarkConcurrent(b *testing.B) {
+	lines := syntheticLines(benchmarkLines, 1000, 1)
+	b.ResetTimer()
+
+	for _, workers := range []int{1, 2, 4, 8, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewHydrothermalVentMapWithWorkers(lines, 1000, 1000, workers)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+module aocd5
+
+go 1.17
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrLineNotInMap = errors.New("line is not part of the vent map")
+
+// lines are stored direction-independent, 0,9 -> 5,9 and 5,9 -> 0,9 cover the same cells
+type lineKey struct {
+	x1, y1, x2, y2 int
+}
+
+func keyOf(l *Line) lineKey {
+	from, to := l.from, l.to
+
+	if to.x < from.x || (to.x == from.x && to.y < from.y) {
+		from, to = to, from
+	}
+
+	return lineKey{from.x, from.y, to.x, to.y}
+}
+
+type VentDiff struct {
+	Point  Point
+	Before int
+	After  int
+}
+
+func (d VentDiff) String() string {
+	return fmt.Sprintf("%d,%d: %d -> %d", d.Point.x, d.Point.y, d.Before, d.After)
+}
+
+func NewEmptyHydrothermalVentMap() *HydrothermalVentMap {
+	return &HydrothermalVentMap{0, [][]int{}, map[lineKey]int{}}
+}
+
+func (h *HydrothermalVentMap) grow(rows, cols int) {
+	for i := range h.layout {
+		for len(h.layout[i]) <= cols {
+			h.layout[i] = append(h.layout[i], 0)
+		}
+	}
+
+	width := cols + 1
+	if len(h.layout) > 0 {
+		width = max(width, len(h.layout[0]))
+	}
+
+	for len(h.layout) <= rows {
+		h.layout = append(h.layout, make([]int, width))
+	}
+}
+
+func (h *HydrothermalVentMap) AddLine(line *Line) {
+	h.grow(line.MaxY(), line.MaxX())
+
+	line.Walk(func(row, col int) {
+		h.layout[row][col] += 1
+
+		if h.layout[row][col] == 2 {
+			h.overlappingVents += 1
+		}
+	})
+
+	h.lines[keyOf(line)] += 1
+}
+
+func (h *HydrothermalVentMap) RemoveLine(line *Line) error {
+	key := keyOf(line)
+
+	if h.lines[key] == 0 {
+		return ErrLineNotInMap
+	}
+
+	line.Walk(func(row, col int) {
+		if h.layout[row][col] == 2 {
+			h.overlappingVents -= 1
+		}
+
+		h.layout[row][col] -= 1
+	})
+
+	h.lines[key] -= 1
+	if h.lines[key] == 0 {
+		delete(h.lines, key)
+	}
+
+	return nil
+}
+
+func (h HydrothermalVentMap) At(x, y int) int {
+	if y < 0 || y >= len(h.layout) || x < 0 || x >= len(h.layout[y]) {
+		return 0
+	}
+	return h.layout[y][x]
+}
+
+func (h HydrothermalVentMap) Snapshot() *HydrothermalVentMap {
+	layout := make([][]int, len(h.layout))
+	for i, row := range h.layout {
+		layout[i] = append([]int{}, row...)
+	}
+
+	lines := make(map[lineKey]int, len(h.lines))
+	for key, count := range h.lines {
+		lines[key] = count
+	}
+
+	return &HydrothermalVentMap{h.overlappingVents, layout, lines}
+}
+
+// cells outside of either layout count as 0, so maps of different sizes can be compared
+func (h HydrothermalVentMap) Diff(other *HydrothermalVentMap) []VentDiff {
+	diffs := []VentDiff{}
+
+	rows := max(len(h.layout), len(other.layout))
+
+	for y := 0; y < rows; y++ {
+		cols := 0
+		if y < len(h.layout) {
+			cols = len(h.layout[y])
+		}
+		if y < len(other.layout) {
+			cols = max(cols, len(other.layout[y]))
+		}
+
+		for x := 0; x < cols; x++ {
+			before := h.At(x, y)
+			after := other.At(x, y)
+
+			if before != after {
+				diffs = append(diffs, VentDiff{Point{x, y}, before, after})
+			}
+		}
+	}
+
+	return diffs
+}
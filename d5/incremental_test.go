@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func assertEmptyMap(t *testing.T, h *HydrothermalVentMap) {
+	t.Helper()
+
+	if h.OverlappingVents() != 0 {
+		t.Errorf("overlapping vents = %d, want 0", h.OverlappingVents())
+	}
+	if len(h.lines) != 0 {
+		t.Errorf("%d lines left in the map", len(h.lines))
+	}
+	if diffs := h.Diff(NewEmptyHydrothermalVentMap()); len(diffs) != 0 {
+		t.Errorf("diff against an empty map = %v", diffs)
+	}
+}
+
+func TestAddThenRemoveReturnsToEmptyMap(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []*Line
+	}{
+		{"single point", []*Line{{&Point{3, 3}, &Point{3, 3}}}},
+		{"crossing", []*Line{
+			{&Point{0, 9}, &Point{5, 9}},
+			{&Point{2, 0}, &Point{2, 9}},
+			{&Point{0, 0}, &Point{9, 9}},
+		}},
+		{"same line twice", []*Line{
+			{&Point{1, 1}, &Point{1, 4}},
+			{&Point{1, 1}, &Point{1, 4}},
+		}},
+		{"three on one cell", []*Line{
+			{&Point{0, 2}, &Point{4, 2}},
+			{&Point{2, 0}, &Point{2, 4}},
+			{&Point{0, 0}, &Point{4, 4}},
+		}},
+		{"synthetic", syntheticLines(500, 50, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewEmptyHydrothermalVentMap()
+			rows, cols := 0, 0
+			for _, line := range tt.lines {
+				h.AddLine(line)
+				rows = max(rows, line.MaxY())
+				cols = max(cols, line.MaxX())
+			}
+
+			want := NewHydrothermalVentMap(tt.lines, rows, cols).OverlappingVents()
+			if h.OverlappingVents() != want {
+				t.Fatalf("overlapping vents after adding = %d, want %d", h.OverlappingVents(), want)
+			}
+
+			order := rand.New(rand.NewSource(int64(len(tt.lines)))).Perm(len(tt.lines))
+			for _, i := range order {
+				if err := h.RemoveLine(tt.lines[i]); err != nil {
+					t.Fatalf("RemoveLine(%v -> %v): %v", *tt.lines[i].from, *tt.lines[i].to, err)
+				}
+			}
+
+			assertEmptyMap(t, h)
+		})
+	}
+}
+
+func TestRemoveLineIgnoresDirection(t *testing.T) {
+	tests := []struct {
+		name    string
+		added   *Line
+		removed *Line
+	}{
+		{"horizontal", &Line{&Point{0, 9}, &Point{5, 9}}, &Line{&Point{5, 9}, &Point{0, 9}}},
+		{"vertical", &Line{&Point{7, 0}, &Point{7, 4}}, &Line{&Point{7, 4}, &Point{7, 0}}},
+		{"diagonal", &Line{&Point{8, 0}, &Point{0, 8}}, &Line{&Point{0, 8}, &Point{8, 0}}},
+		{"same direction", &Line{&Point{1, 1}, &Point{3, 3}}, &Line{&Point{1, 1}, &Point{3, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewEmptyHydrothermalVentMap()
+			h.AddLine(tt.added)
+
+			if err := h.RemoveLine(tt.removed); err != nil {
+				t.Fatalf("RemoveLine: %v", err)
+			}
+
+			assertEmptyMap(t, h)
+
+			if err := h.RemoveLine(tt.removed); err != ErrLineNotInMap {
+				t.Errorf("removing the line twice returned %v, want ErrLineNotInMap", err)
+			}
+		})
+	}
+}
+
+func TestRemoveLineNotInMap(t *testing.T) {
+	h := NewEmptyHydrothermalVentMap()
+	h.AddLine(&Line{&Point{0, 0}, &Point{4, 0}})
+	before := h.Snapshot()
+
+	if err := h.RemoveLine(&Line{&Point{0, 0}, &Point{3, 0}}); err != ErrLineNotInMap {
+		t.Fatalf("RemoveLine returned %v, want ErrLineNotInMap", err)
+	}
+
+	if diffs := before.Diff(h); len(diffs) != 0 {
+		t.Errorf("failed RemoveLine changed the map: %v", diffs)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := NewEmptyHydrothermalVentMap()
+	before.AddLine(&Line{&Point{0, 0}, &Point{2, 0}})
+
+	after := before.Snapshot()
+	after.AddLine(&Line{&Point{1, 0}, &Point{1, 3}})
+
+	want := []VentDiff{
+		{Point{1, 0}, 1, 2},
+		{Point{1, 1}, 0, 1},
+		{Point{1, 2}, 0, 1},
+		{Point{1, 3}, 0, 1},
+	}
+
+	diffs := before.Diff(after)
+	if len(diffs) != len(want) {
+		t.Fatalf("Diff = %v, want %v", diffs, want)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("Diff[%d] = %v, want %v", i, diffs[i], want[i])
+		}
+	}
+}
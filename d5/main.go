@@ -2,12 +2,12 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"strconv"
-	"strings"
+	"runtime"
 )
 
 func readLines(reader io.Reader) ([]string, error) {
@@ -40,8 +40,11 @@ func (dataSource AdventOfCodeFileDataSourceDay3) Read() ([]string, error) {
 }
 
 type AdventOfCodeDay3Solution struct {
-	dataSource AdventOfCodeDataSource
-	data       []*Line
+	dataSource   AdventOfCodeDataSource
+	data         []*Line
+	parseOptions ParseOptions
+	// goroutines that rasterise the vent map, 1 draws it sequentially
+	workers int
 }
 
 type Point struct {
@@ -70,45 +73,24 @@ func (l Line) IsVertical() bool {
 	return l.from.x == l.to.x
 }
 
-func toInt(str string) int {
-	num, err := strconv.Atoi(str)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return num
-}
-
-func parseLine(lineStr string) *Line {
-	lines := strings.Split(lineStr, " -> ")
-	point1 := strings.Split(lines[0], ",")
-	point2 := strings.Split(lines[1], ",")
-	x1 := toInt(point1[0])
-	y1 := toInt(point1[1])
-	x2 := toInt(point2[0])
-	y2 := toInt(point2[1])
-	from := &Point{x1, y1}
-	to := &Point{x2, y2}
-	return &Line{from, to}
-}
-
-func parseLines(data []string) []*Line {
-	lines := []*Line{}
-	for _, lineStr := range data {
-		line := parseLine(lineStr)
-		lines = append(lines, line)
-	}
-	return lines
-}
-
 func (solution *AdventOfCodeDay3Solution) Data() []*Line {
 	if solution.data == nil {
 		data, err := solution.dataSource.Read()
-		lines := parseLines(data)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
+		lines, warnings, err := ParseLines(data, solution.parseOptions)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, warning := range warnings {
+			log.Printf("skipping invalid line: %v", warning)
+		}
+
 		solution.data = lines
 	}
 
@@ -125,6 +107,7 @@ func max(a int, b int) int {
 type HydrothermalVentMap struct {
 	overlappingVents int
 	layout           [][]int
+	lines            map[lineKey]int
 }
 
 func (h HydrothermalVentMap) Print() {
@@ -151,41 +134,47 @@ func initLayout(rows, cols int) [][]int {
 	return layout
 }
 
-func getNumOfOverlappingVents(lines []*Line, layout [][]int) int {
-	overlappingVents := 0
+func (l Line) Walk(visit func(row, col int)) {
+	currX := l.from.y
+	currY := l.from.x
 
-	// this is super inefficient if we don't care about the map layout
+	endX := l.to.y
+	endY := l.to.x
 
-	for _, line := range lines {
-		currX := line.from.y
-		currY := line.from.x
+	for {
+		visit(currX, currY)
 
-		endX := line.to.y
-		endY := line.to.x
+		if currX == endX && currY == endY {
+			break
+		}
 
-		for {
-			layout[currX][currY] += 1
+		if currX > endX {
+			currX -= 1
+		} else if currX < endX {
+			currX += 1
+		}
 
-			if layout[currX][currY] == 2 {
-				overlappingVents += 1
-			}
+		if currY > endY {
+			currY -= 1
+		} else if currY < endY {
+			currY += 1
+		}
+	}
+}
 
-			if currX == endX && currY == endY {
-				break
-			}
+func getNumOfOverlappingVents(lines []*Line, layout [][]int) int {
+	overlappingVents := 0
 
-			if currX > endX {
-				currX -= 1
-			} else if currX < endX {
-				currX += 1
-			}
+	// this is super inefficient if we don't care about the map layout
 
-			if currY > endY {
-				currY -= 1
-			} else if currY < endY {
-				currY += 1
+	for _, line := range lines {
+		line.Walk(func(row, col int) {
+			layout[row][col] += 1
+
+			if layout[row][col] == 2 {
+				overlappingVents += 1
 			}
-		}
+		})
 	}
 
 	return overlappingVents
@@ -194,7 +183,12 @@ func getNumOfOverlappingVents(lines []*Line, layout [][]int) int {
 func NewHydrothermalVentMap(lines []*Line, rows int, cols int) *HydrothermalVentMap {
 	layout := initLayout(rows, cols)
 	overlappingVents := getNumOfOverlappingVents(lines, layout)
-	ventmap := &HydrothermalVentMap{overlappingVents, layout}
+	ventmap := &HydrothermalVentMap{overlappingVents, layout, map[lineKey]int{}}
+
+	for _, line := range lines {
+		ventmap.lines[keyOf(line)] += 1
+	}
+
 	return ventmap
 }
 
@@ -212,7 +206,7 @@ func (solution AdventOfCodeDay3Solution) Part1() int {
 		}
 	}
 
-	ventMap := NewHydrothermalVentMap(horizontalOrVerticalLines, rows, cols)
+	ventMap := NewHydrothermalVentMapWithWorkers(horizontalOrVerticalLines, rows, cols, solution.workers)
 	return ventMap.OverlappingVents()
 }
 
@@ -226,13 +220,17 @@ func (solution AdventOfCodeDay3Solution) Part2() int {
 		cols = max(cols, line.MaxX())
 	}
 
-	ventMap := NewHydrothermalVentMap(data, rows, cols)
+	ventMap := NewHydrothermalVentMapWithWorkers(data, rows, cols, solution.workers)
 	return ventMap.OverlappingVents()
 }
 
 func main() {
+	workers := flag.Int("workers", runtime.NumCPU(), "number of goroutines used for rasterisation")
+	skipInvalid := flag.Bool("skip-invalid", false, "skip invalid input lines with a warning instead of failing")
+	flag.Parse()
+
 	fileDataSource := AdventOfCodeFileDataSourceDay3{"test_data2"}
-	solution := AdventOfCodeDay3Solution{fileDataSource, nil}
+	solution := AdventOfCodeDay3Solution{fileDataSource, nil, ParseOptions{*skipInvalid}, *workers}
 	fmt.Println(solution.Part1())
 	fmt.Println(solution.Part2())
 }
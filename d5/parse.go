@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type ParseError struct {
+	Line   int
+	Column int
+	Token  string
+	Reason string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s (got %q)", e.Line, e.Column, e.Reason, e.Token)
+}
+
+type ParseOptions struct {
+	// skip invalid lines and collect their errors as warnings instead of failing
+	SkipInvalid bool
+}
+
+type lineScanner struct {
+	text   string
+	pos    int
+	lineNo int
+}
+
+func (s *lineScanner) skipSpaces() {
+	for s.pos < len(s.text) && unicode.IsSpace(rune(s.text[s.pos])) {
+		s.pos++
+	}
+}
+
+func (s *lineScanner) errorf(token string, format string, args ...interface{}) error {
+	return ParseError{s.lineNo, s.pos + 1, token, fmt.Sprintf(format, args...)}
+}
+
+// the next run of characters up to a space or ',', used to report the offending token
+func (s *lineScanner) peekToken() string {
+	end := s.pos
+	for end < len(s.text) && !unicode.IsSpace(rune(s.text[end])) && s.text[end] != ',' {
+		end++
+	}
+	if end == s.pos && end < len(s.text) {
+		end++
+	}
+	return s.text[s.pos:end]
+}
+
+func (s *lineScanner) number() (int, error) {
+	s.skipSpaces()
+	start := s.pos
+
+	for s.pos < len(s.text) && s.text[s.pos] >= '0' && s.text[s.pos] <= '9' {
+		s.pos++
+	}
+
+	if start == s.pos {
+		return 0, s.errorf(s.peekToken(), "expected a number")
+	}
+
+	num, err := strconv.Atoi(s.text[start:s.pos])
+	if err != nil {
+		s.pos = start
+		return 0, s.errorf(s.peekToken(), "number out of range")
+	}
+
+	return num, nil
+}
+
+func (s *lineScanner) expect(literal string) error {
+	s.skipSpaces()
+
+	if !strings.HasPrefix(s.text[s.pos:], literal) {
+		return s.errorf(s.peekToken(), "expected %q", literal)
+	}
+
+	s.pos += len(literal)
+	return nil
+}
+
+func (s *lineScanner) point() (*Point, error) {
+	x, err := s.number()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.expect(","); err != nil {
+		return nil, err
+	}
+
+	y, err := s.number()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Point{x, y}, nil
+}
+
+func parseLineStrict(lineStr string, lineNo int) (*Line, error) {
+	s := &lineScanner{lineStr, 0, lineNo}
+
+	from, err := s.point()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.expect("->"); err != nil {
+		return nil, err
+	}
+
+	to, err := s.point()
+	if err != nil {
+		return nil, err
+	}
+
+	s.skipSpaces()
+	if s.pos < len(s.text) {
+		return nil, s.errorf(s.text[s.pos:], "unexpected trailing input")
+	}
+
+	line := &Line{from, to}
+
+	if !line.IsHorizontal() && !line.IsVertical() && abs(to.x-from.x) != abs(to.y-from.y) {
+		return nil, ParseError{lineNo, 1, strings.TrimSpace(lineStr), "line is neither horizontal, vertical nor diagonal"}
+	}
+
+	return line, nil
+}
+
+// line numbers in errors are 1-based, blank lines are ignored
+func ParseLines(data []string, options ParseOptions) ([]*Line, []ParseError, error) {
+	lines := []*Line{}
+	warnings := []ParseError{}
+
+	for i, lineStr := range data {
+		if strings.TrimSpace(lineStr) == "" {
+			continue
+		}
+
+		line, err := parseLineStrict(lineStr, i+1)
+		if err != nil {
+			if !options.SkipInvalid {
+				return nil, nil, err
+			}
+			warnings = append(warnings, err.(ParseError))
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, warnings, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestParseLineStrictErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ParseError
+	}{
+		{"x,9 -> 5,9", ParseError{1, 1, "x", "expected a number"}},
+		{"0,9 -> 5,", ParseError{1, 10, "", "expected a number"}},
+		{"  0,-9 -> 5,9", ParseError{1, 5, "-9", "expected a number"}},
+		{"0,99999999999999999999 -> 5,9", ParseError{1, 3, "99999999999999999999", "number out of range"}},
+		{"0;9 -> 5,9", ParseError{1, 2, ";9", `expected ","`}},
+		{"0,9 => 5,9", ParseError{1, 5, "=>", `expected "->"`}},
+		{"0,9 5,9", ParseError{1, 5, "5", `expected "->"`}},
+		{"0,9 -> 5,9 -> 7,9", ParseError{1, 12, "-> 7,9", "unexpected trailing input"}},
+		{" 0,0 -> 8,3 ", ParseError{1, 1, "0,0 -> 8,3", "line is neither horizontal, vertical nor diagonal"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parseLineStrict(tt.input, 1)
+
+			got, ok := err.(ParseError)
+			if !ok {
+				t.Fatalf("parseLineStrict(%q) returned %v, want a ParseError", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseLineStrict(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	data := []string{
+		"0,9 -> 5,9",
+		"",
+		"8,0 -> 0,8",
+		"0,0 -> 8,3",
+		"9,4 -> 3,4",
+	}
+
+	if _, _, err := ParseLines(data, ParseOptions{}); err != (ParseError{4, 1, "0,0 -> 8,3", "line is neither horizontal, vertical nor diagonal"}) {
+		t.Fatalf("ParseLines returned %v, want the error on line 4", err)
+	}
+
+	lines, warnings, err := ParseLines(data, ParseOptions{SkipInvalid: true})
+	if err != nil {
+		t.Fatalf("ParseLines with SkipInvalid returned %v", err)
+	}
+	if len(lines) != 3 {
+		t.Errorf("parsed %d lines, want 3", len(lines))
+	}
+	if len(warnings) != 1 || warnings[0].Line != 4 {
+		t.Errorf("warnings = %v, want one on line 4", warnings)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// one byte per cell and no per-cell allocations, for maps far larger than the puzzle input.
+// Basins here are the connected regions of non-wall cells, which is what Part2 finds
+// whenever every basin has a single low point, as the puzzle guarantees
+type CompactHeightmap struct {
+	rows  int
+	cols  int
+	cells []byte
+}
+
+type CompactOptions struct {
+	// rows labelled at a time by one worker, the label memory per worker is StripeRows*cols*4 bytes
+	StripeRows int
+	Workers    int
+	WallHeight byte
+}
+
+func DefaultCompactOptions() CompactOptions {
+	return CompactOptions{StripeRows: 256, Workers: 1, WallHeight: 9}
+}
+
+func ReadCompactHeightmap(r io.Reader) (*CompactHeightmap, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<20), 1<<30)
+
+	h := &CompactHeightmap{}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		if h.rows == 0 {
+			h.cols = len(line)
+		} else if len(line) != h.cols {
+			return nil, fmt.Errorf("line %d has %d columns, expected %d", h.rows+1, len(line), h.cols)
+		}
+
+		for i, ch := range line {
+			if ch < '0' || ch > '9' {
+				return nil, fmt.Errorf("line %d, column %d: %q is not a digit", h.rows+1, i+1, ch)
+			}
+			h.cells = append(h.cells, ch-'0')
+		}
+
+		h.rows++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *CompactHeightmap) Rows() int {
+	return h.rows
+}
+
+func (h *CompactHeightmap) Cols() int {
+	return h.cols
+}
+
+func (h *CompactHeightmap) isLowPoint(i int) bool {
+	val := h.cells[i]
+	row, col := i/h.cols, i%h.cols
+
+	if row > 0 && h.cells[i-h.cols] <= val {
+		return false
+	}
+	if row < h.rows-1 && h.cells[i+h.cols] <= val {
+		return false
+	}
+	if col > 0 && h.cells[i-1] <= val {
+		return false
+	}
+	if col < h.cols-1 && h.cells[i+1] <= val {
+		return false
+	}
+
+	return true
+}
+
+type stripeResult struct {
+	index  int
+	risk   int
+	sizes  []int64
+	top    []int32
+	bottom []int32
+}
+
+// labels the non-wall regions of one stripe with a flood fill that only grows a reused stack
+type stripeWorker struct {
+	h       *CompactHeightmap
+	options CompactOptions
+	labels  []int32
+	stack   []int32
+}
+
+func (w *stripeWorker) run(index int) stripeResult {
+	h := w.h
+	lo := index * w.options.StripeRows
+	hi := lo + w.options.StripeRows
+	if hi > h.rows {
+		hi = h.rows
+	}
+
+	n := (hi - lo) * h.cols
+	labels := w.labels[:n]
+	for i := range labels {
+		labels[i] = -1
+	}
+
+	base := lo * h.cols
+	result := stripeResult{index: index}
+
+	for i := 0; i < n; i++ {
+		if h.cells[base+i] >= w.options.WallHeight {
+			continue
+		}
+
+		if h.isLowPoint(base + i) {
+			result.risk += int(h.cells[base+i]) + 1
+		}
+
+		if labels[i] != -1 {
+			continue
+		}
+
+		label := int32(len(result.sizes))
+		size := int64(0)
+
+		labels[i] = label
+		w.stack = append(w.stack[:0], int32(i))
+
+		for len(w.stack) > 0 {
+			j := int(w.stack[len(w.stack)-1])
+			w.stack = w.stack[:len(w.stack)-1]
+			size++
+
+			row, col := j/h.cols, j%h.cols
+
+			if row > 0 {
+				w.visit(labels, base, j-h.cols, label)
+			}
+			if row < hi-lo-1 {
+				w.visit(labels, base, j+h.cols, label)
+			}
+			if col > 0 {
+				w.visit(labels, base, j-1, label)
+			}
+			if col < h.cols-1 {
+				w.visit(labels, base, j+1, label)
+			}
+		}
+
+		result.sizes = append(result.sizes, size)
+	}
+
+	result.top = append([]int32{}, labels[:h.cols]...)
+	result.bottom = append([]int32{}, labels[n-h.cols:n]...)
+
+	return result
+}
+
+func (w *stripeWorker) visit(labels []int32, base int, j int, label int32) {
+	if labels[j] == -1 && w.h.cells[base+j] < w.options.WallHeight {
+		labels[j] = label
+		w.stack = append(w.stack, int32(j))
+	}
+}
+
+type unionFind struct {
+	parent []int32
+	size   []int64
+}
+
+func (u *unionFind) find(x int32) int32 {
+	for u.parent[x] != x {
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+func (u *unionFind) union(a, b int32) {
+	a, b = u.find(a), u.find(b)
+	if a == b {
+		return
+	}
+	if u.size[a] < u.size[b] {
+		a, b = b, a
+	}
+	u.parent[b] = a
+	u.size[a] += u.size[b]
+}
+
+func (options CompactOptions) Validate() error {
+	if options.StripeRows < 1 {
+		return fmt.Errorf("stripe rows must be at least 1, got %d", options.StripeRows)
+	}
+	if options.Workers < 1 {
+		return fmt.Errorf("workers must be at least 1, got %d", options.Workers)
+	}
+	return nil
+}
+
+// Part1 and the size of every basin, the map is labelled stripe by stripe and
+// regions touching across a stripe boundary are merged afterwards
+func (h *CompactHeightmap) Analyze(options CompactOptions) (int, []int64, error) {
+	if err := options.Validate(); err != nil {
+		return 0, nil, err
+	}
+
+	if h.rows == 0 || h.cols == 0 {
+		return 0, nil, nil
+	}
+
+	stripes := (h.rows + options.StripeRows - 1) / options.StripeRows
+	results := make([]stripeResult, stripes)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			worker := &stripeWorker{h, options, make([]int32, options.StripeRows*h.cols), make([]int32, 0, h.cols)}
+			for index := range jobs {
+				results[index] = worker.run(index)
+			}
+		}()
+	}
+
+	for i := 0; i < stripes; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	risk := 0
+	offsets := make([]int32, stripes)
+	uf := &unionFind{}
+
+	for i, result := range results {
+		risk += result.risk
+		offsets[i] = int32(len(uf.parent))
+
+		for _, size := range result.sizes {
+			uf.parent = append(uf.parent, int32(len(uf.parent)))
+			uf.size = append(uf.size, size)
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		above := results[i-1].bottom
+		for col, label := range result.top {
+			if label >= 0 && above[col] >= 0 {
+				uf.union(offsets[i-1]+above[col], offsets[i]+label)
+			}
+		}
+	}
+
+	sizes := []int64{}
+	for i := range uf.parent {
+		if uf.find(int32(i)) == int32(i) {
+			sizes = append(sizes, uf.size[i])
+		}
+	}
+
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	return risk, sizes, nil
+}
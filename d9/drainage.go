@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"aocgrid"
+)
+
+type TieRule int
+
+const (
+	// among equally low neighbours the first one in direction order wins
+	TieFirstDirection TieRule = iota
+	// a cell whose equally low neighbours drain into different basins is left unassigned
+	TieUnassigned
+)
+
+type PlateauRule int
+
+const (
+	// flat areas drain towards their nearest lower exit, flat areas without an exit form one basin
+	PlateauSpread PlateauRule = iota
+	// only strict single cell low points start a basin and flat cells never drain sideways,
+	// which is what the flood fill of Part2 does
+	PlateauStrict
+)
+
+type DrainageOptions struct {
+	Ties     TieRule
+	Plateaus PlateauRule
+}
+
+const (
+	labelWall       = -1
+	labelUnassigned = -2
+	labelPending    = -3
+)
+
+type Drainage struct {
+	// basin index per cell, or one of the negative label constants
+	Labels     *aocgrid.Grid[int]
+	Basins     []Basin
+	Unassigned []aocgrid.Point
+}
+
+func (d *Drainage) Report() string {
+	var report strings.Builder
+
+	fmt.Fprintf(&report, "%d basins, %d unassigned cells\n", len(d.Basins), len(d.Unassigned))
+
+	for i, basin := range d.Basins {
+		fmt.Fprintf(&report, "basin %d: low point %d,%d, size %d, rim %d\n",
+			i, basin.LowPoint.Row, basin.LowPoint.Col, basin.Size(), basin.RimHeight)
+	}
+
+	for _, p := range d.Unassigned {
+		fmt.Fprintf(&report, "unassigned: %d,%d\n", p.Row, p.Col)
+	}
+
+	return report.String()
+}
+
+// every non-wall cell flows to its lowest neighbour until it reaches a sink, cells are
+// resolved from the lowest height upwards so the cells they drain into are already labelled
+func (h *Heightmap) Drain(options DrainageOptions) *Drainage {
+	labels := aocgrid.Map(h.cells, func(val int) int {
+		if h.isWall(val) {
+			return labelWall
+		}
+		return labelPending
+	})
+
+	order := []aocgrid.Point{}
+	h.cells.Each(func(row, col, val int) {
+		if !h.isWall(val) {
+			order = append(order, aocgrid.Point{Row: row, Col: col})
+		}
+	})
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return h.cells.At(order[i].Row, order[i].Col) < h.cells.At(order[j].Row, order[j].Col)
+	})
+
+	sinks := []aocgrid.Point{}
+
+	for _, p := range order {
+		if labels.At(p.Row, p.Col) != labelPending {
+			continue
+		}
+
+		plateau := h.plateau(labels, p)
+		exits := []aocgrid.Point{}
+
+		for _, cell := range plateau {
+			label, ok := h.descend(labels, cell, options.Ties)
+			if ok {
+				labels.Set(cell.Row, cell.Col, label)
+				exits = append(exits, cell)
+			}
+		}
+
+		switch {
+		case len(exits) == 0 && (options.Plateaus == PlateauSpread || len(plateau) == 1):
+			for _, cell := range plateau {
+				labels.Set(cell.Row, cell.Col, len(sinks))
+			}
+			sinks = append(sinks, plateau[0])
+		case len(exits) > 0 && options.Plateaus == PlateauSpread:
+			h.spread(labels, plateau, exits, options.Ties)
+		default:
+			for _, cell := range plateau {
+				if labels.At(cell.Row, cell.Col) == labelPending {
+					labels.Set(cell.Row, cell.Col, labelUnassigned)
+				}
+			}
+		}
+	}
+
+	members := make([][]aocgrid.Point, len(sinks))
+	unassigned := []aocgrid.Point{}
+
+	labels.Each(func(row, col, label int) {
+		p := aocgrid.Point{Row: row, Col: col}
+		if label >= 0 {
+			members[label] = append(members[label], p)
+		} else if label == labelUnassigned {
+			unassigned = append(unassigned, p)
+		}
+	})
+
+	basins := make([]Basin, len(sinks))
+	for i, sink := range sinks {
+		basins[i] = Basin{sink, members[i], h.rimHeight(members[i])}
+	}
+
+	return &Drainage{labels, basins, unassigned}
+}
+
+// the pending cells connected to start that have the same height
+func (h *Heightmap) plateau(labels *aocgrid.Grid[int], start aocgrid.Point) []aocgrid.Point {
+	height := h.cells.At(start.Row, start.Col)
+	seen := map[aocgrid.Point]bool{start: true}
+	plateau := []aocgrid.Point{start}
+
+	for i := 0; i < len(plateau); i++ {
+		p := plateau[i]
+		h.neighbours(p.Row, p.Col, func(nextRow, nextCol, nextVal int) {
+			next := aocgrid.Point{Row: nextRow, Col: nextCol}
+			if nextVal == height && !seen[next] && labels.At(nextRow, nextCol) == labelPending {
+				seen[next] = true
+				plateau = append(plateau, next)
+			}
+		})
+	}
+
+	return plateau
+}
+
+// the label of the steepest strictly lower neighbour, false if there is none
+func (h *Heightmap) descend(labels *aocgrid.Grid[int], p aocgrid.Point, ties TieRule) (int, bool) {
+	val := h.cells.At(p.Row, p.Col)
+	lowest := val
+	label := labelPending
+
+	h.neighbours(p.Row, p.Col, func(nextRow, nextCol, nextVal int) {
+		if h.isWall(nextVal) || nextVal >= val {
+			return
+		}
+
+		nextLabel := labels.At(nextRow, nextCol)
+
+		if nextVal < lowest {
+			lowest = nextVal
+			label = nextLabel
+		} else if nextVal == lowest && nextLabel != label && ties == TieUnassigned {
+			label = labelUnassigned
+		}
+	})
+
+	return label, lowest < val
+}
+
+// breadth first from the exits across the rest of the plateau, every cell
+// takes the label of the exit it is closest to
+func (h *Heightmap) spread(labels *aocgrid.Grid[int], plateau []aocgrid.Point, exits []aocgrid.Point, ties TieRule) {
+	onPlateau := make(map[aocgrid.Point]bool, len(plateau))
+	for _, p := range plateau {
+		onPlateau[p] = true
+	}
+
+	frontier := exits
+
+	for len(frontier) > 0 {
+		next := []aocgrid.Point{}
+		claimed := map[aocgrid.Point]int{}
+
+		for _, p := range frontier {
+			label := labels.At(p.Row, p.Col)
+
+			h.neighbours(p.Row, p.Col, func(nextRow, nextCol, _ int) {
+				cell := aocgrid.Point{Row: nextRow, Col: nextCol}
+
+				if !onPlateau[cell] || labels.At(nextRow, nextCol) != labelPending {
+					return
+				}
+
+				current, ok := claimed[cell]
+
+				if !ok {
+					claimed[cell] = label
+					next = append(next, cell)
+				} else if current != label && ties == TieUnassigned {
+					claimed[cell] = labelUnassigned
+				}
+			})
+		}
+
+		for _, cell := range next {
+			labels.Set(cell.Row, cell.Col, claimed[cell])
+		}
+
+		frontier = next
+	}
+}
@@ -0,0 +1,7 @@
+module aocd9
+
+go 1.18
+
+require aocgrid v0.0.0
+
+replace aocgrid => ../grid
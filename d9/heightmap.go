@@ -0,0 +1,231 @@
+package main
+
+import (
+	"sort"
+
+	"aocgrid"
+)
+
+type Connectivity int
+
+const (
+	Connect4 Connectivity = 4
+	Connect8 Connectivity = 8
+)
+
+// the zero value is the puzzle's options, a WallHeight of 0 without IsWall means 9
+type HeightmapOptions struct {
+	// cells at or above WallHeight are basin walls
+	WallHeight int
+	// replaces the WallHeight check when set
+	IsWall       func(height int) bool
+	Connectivity Connectivity
+	// toroidal edges, the first and last rows and columns are neighbours
+	Wrap bool
+}
+
+func DefaultHeightmapOptions() HeightmapOptions {
+	return HeightmapOptions{WallHeight: 9, Connectivity: Connect4}
+}
+
+type Heightmap struct {
+	cells   *aocgrid.Grid[int]
+	options HeightmapOptions
+}
+
+func NewHeightmap(cells *aocgrid.Grid[int]) *Heightmap {
+	return NewHeightmapWithOptions(cells, DefaultHeightmapOptions())
+}
+
+func NewHeightmapWithOptions(cells *aocgrid.Grid[int], options HeightmapOptions) *Heightmap {
+	return &Heightmap{cells, options.withDefaults()}
+}
+
+// every cell being a wall is never what a caller means, so an unset WallHeight falls back to the default
+func (o HeightmapOptions) withDefaults() HeightmapOptions {
+	defaults := DefaultHeightmapOptions()
+
+	if o.WallHeight == 0 && o.IsWall == nil {
+		o.WallHeight = defaults.WallHeight
+	}
+	if o.Connectivity == 0 {
+		o.Connectivity = defaults.Connectivity
+	}
+
+	return o
+}
+
+type Basin struct {
+	LowPoint  aocgrid.Point
+	Cells     []aocgrid.Point
+	RimHeight int
+}
+
+func (b Basin) Size() int {
+	return len(b.Cells)
+}
+
+type BasinStats struct {
+	Size       int
+	LowHeight  int
+	MaxHeight  int
+	MeanHeight float64
+	RimHeight  int
+	// rim height minus low point height, how deep the basin could be filled before spilling
+	Depth int
+}
+
+func (h *Heightmap) isWall(val int) bool {
+	if h.options.IsWall != nil {
+		return h.options.IsWall(val)
+	}
+	return val >= h.options.WallHeight
+}
+
+func (h *Heightmap) neighbours(row, col int, visit func(row, col int, val int)) {
+	directions := aocgrid.Directions4
+	if h.options.Connectivity == Connect8 {
+		directions = aocgrid.Directions8
+	}
+
+	if h.options.Wrap {
+		h.cells.NeighboursWrap(row, col, directions, visit)
+	} else {
+		h.cells.Neighbours(row, col, directions, visit)
+	}
+}
+
+// walls are never low points and do not count as neighbours
+func (h *Heightmap) IsLowPoint(row, col int) bool {
+	val := h.cells.At(row, col)
+
+	if h.isWall(val) {
+		return false
+	}
+
+	isLowPoint := true
+
+	h.neighbours(row, col, func(nextRow, nextCol, nextVal int) {
+		if (nextRow != row || nextCol != col) && !h.isWall(nextVal) && val >= nextVal {
+			isLowPoint = false
+		}
+	})
+
+	return isLowPoint
+}
+
+func (h *Heightmap) LowPoints() []aocgrid.Point {
+	lowPoints := []aocgrid.Point{}
+
+	h.cells.Each(func(row, col, _ int) {
+		if h.IsLowPoint(row, col) {
+			lowPoints = append(lowPoints, aocgrid.Point{Row: row, Col: col})
+		}
+	})
+
+	return lowPoints
+}
+
+// the heightmap is only read, visited cells are tracked separately so the
+// same grid can be searched any number of times, also from several goroutines
+func (h *Heightmap) basin(visited *aocgrid.Grid[bool], lowPoint aocgrid.Point) Basin {
+	queue := []aocgrid.Point{lowPoint}
+	visited.Set(lowPoint.Row, lowPoint.Col, true)
+	members := []aocgrid.Point{}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		members = append(members, p)
+		val := h.cells.At(p.Row, p.Col)
+
+		h.neighbours(p.Row, p.Col, func(nextRow, nextCol, nextVal int) {
+			if !h.isWall(nextVal) && nextVal > val && !visited.At(nextRow, nextCol) {
+				queue = append(queue, aocgrid.Point{Row: nextRow, Col: nextCol})
+				visited.Set(nextRow, nextCol, true)
+			}
+		})
+	}
+
+	return Basin{lowPoint, members, h.rimHeight(members)}
+}
+
+// the lowest cell bordering the basin, -1 if nothing borders it
+func (h *Heightmap) rimHeight(members []aocgrid.Point) int {
+	inBasin := make(map[aocgrid.Point]bool, len(members))
+	for _, p := range members {
+		inBasin[p] = true
+	}
+
+	rim := -1
+
+	for _, p := range members {
+		h.neighbours(p.Row, p.Col, func(nextRow, nextCol, nextVal int) {
+			if inBasin[aocgrid.Point{Row: nextRow, Col: nextCol}] {
+				return
+			}
+			if rim == -1 || nextVal < rim {
+				rim = nextVal
+			}
+		})
+	}
+
+	return rim
+}
+
+// every basin in row-major order of its low point
+func (h *Heightmap) Basins() []Basin {
+	visited := aocgrid.New[bool](h.cells.Rows(), h.cells.Cols())
+	basins := []Basin{}
+
+	for _, lowPoint := range h.LowPoints() {
+		if visited.At(lowPoint.Row, lowPoint.Col) {
+			continue
+		}
+		basins = append(basins, h.basin(visited, lowPoint))
+	}
+
+	return basins
+}
+
+// the k largest basins, largest first, ties keep their original order
+func TopBasins(basins []Basin, k int) []Basin {
+	sorted := append([]Basin{}, basins...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Size() > sorted[j].Size()
+	})
+
+	if k < len(sorted) {
+		sorted = sorted[:k]
+	}
+
+	return sorted
+}
+
+func (h *Heightmap) Stats(b Basin) BasinStats {
+	stats := BasinStats{
+		Size:      b.Size(),
+		LowHeight: h.cells.At(b.LowPoint.Row, b.LowPoint.Col),
+		RimHeight: b.RimHeight,
+	}
+
+	sum := 0
+	for _, p := range b.Cells {
+		val := h.cells.At(p.Row, p.Col)
+		sum += val
+		if val > stats.MaxHeight {
+			stats.MaxHeight = val
+		}
+	}
+
+	if stats.Size > 0 {
+		stats.MeanHeight = float64(sum) / float64(stats.Size)
+	}
+
+	if stats.RimHeight >= 0 {
+		stats.Depth = stats.RimHeight - stats.LowHeight
+	}
+
+	return stats
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"aocgrid"
+)
+
+func TestZeroHeightmapOptionsAreTheDefaults(t *testing.T) {
+	cells, err := aocgrid.ParseDigits([]string{
+		"2199943210",
+		"3987894921",
+		"9856789892",
+		"8767896789",
+		"9899965678",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zero := NewAdventOfCodeDay9SolutionFromGrid(cells, HeightmapOptions{})
+	defaults := NewAdventOfCodeDay9SolutionFromGrid(cells, DefaultHeightmapOptions())
+
+	if zero.Part1() != 15 || defaults.Part1() != 15 {
+		t.Errorf("Part1 = %d with zero options, %d with defaults, want 15", zero.Part1(), defaults.Part1())
+	}
+	if zero.Part2() != 1134 || defaults.Part2() != 1134 {
+		t.Errorf("Part2 = %d with zero options, %d with defaults, want 1134", zero.Part2(), defaults.Part2())
+	}
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
+
+	"aocgrid"
 )
 
 func readLines(reader io.Reader) ([]string, error) {
@@ -41,176 +46,219 @@ func (dataSource AdventOfCodeFileDataSourceDay9) Read() ([]string, error) {
 
 type AdventOfCodeDay9Solution struct {
 	dataSource AdventOfCodeDataSource
-	data       [][]int
+	data       *aocgrid.Grid[int]
+	options    HeightmapOptions
 }
 
-func parseGrid(data []string) [][]int {
-	grid := [][]int{}
-
-	for _, row := range data {
-		rowValStrs := strings.Split(row, "")
-		rowVals := []int{}
-		for _, valStr := range rowValStrs {
-			val, err := strconv.Atoi(valStr)
-			if err != nil {
-				log.Fatal(err)
-			}
-			rowVals = append(rowVals, val)
-		}
-		grid = append(grid, rowVals)
-
-	}
-	return grid
+// a solution built from an already parsed heightmap, Data never reads from a source
+func NewAdventOfCodeDay9SolutionFromGrid(grid *aocgrid.Grid[int], options HeightmapOptions) AdventOfCodeDay9Solution {
+	return AdventOfCodeDay9Solution{nil, grid, options}
 }
 
-func (solution *AdventOfCodeDay9Solution) Data() [][]int {
+func (solution *AdventOfCodeDay9Solution) Data() *aocgrid.Grid[int] {
 	if solution.data == nil {
 		data, err := solution.dataSource.Read()
-		lines := parseGrid(data)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		solution.data = lines
+		grid, err := aocgrid.ParseDigits(data)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		solution.data = grid
 	}
 
 	return solution.data
 }
 
+func (solution AdventOfCodeDay9Solution) Heightmap() *Heightmap {
+	return NewHeightmapWithOptions(solution.Data(), solution.options)
+}
+
 func (solution AdventOfCodeDay9Solution) Part1() int {
-	data := solution.Data()
+	heightmap := solution.Heightmap()
+	total := 0
 
-	directions := [4][2]int{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+	for _, p := range heightmap.LowPoints() {
+		total += (heightmap.cells.At(p.Row, p.Col) + 1)
+	}
 
-	total := 0
+	return total
+}
+
+func (solution AdventOfCodeDay9Solution) Part2() int {
+	total := 1
 
-	for i := 0; i < len(data); i++ {
-		for j := 0; j < len(data[i]); j++ {
-			val := data[i][j]
+	for _, basin := range TopBasins(solution.Heightmap().Basins(), 3) {
+		total *= basin.Size()
+	}
 
-			isLowPoint := true
+	return total
+}
 
-			for _, direction := range directions {
-				nextRow := i + direction[0]
-				nextCol := j + direction[1]
+func runLarge(path string, options CompactOptions) error {
+	start := time.Now()
 
-				if nextRow < 0 || nextCol < 0 {
-					continue
-				}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-				if nextRow == len(data) || nextCol == len(data[0]) {
-					continue
-				}
+	heightmap, err := ReadCompactHeightmap(f)
+	if err != nil {
+		return err
+	}
 
-				if val >= data[nextRow][nextCol] {
-					isLowPoint = false
-					break
-				}
-			}
+	parsed := time.Now()
+	risk, sizes, err := heightmap.Analyze(options)
+	if err != nil {
+		return err
+	}
 
-			if isLowPoint {
-				total += (val + 1)
-			}
-		}
+	product := int64(1)
+	for i := 0; i < 3 && i < len(sizes); i++ {
+		product *= sizes[i]
 	}
 
-	return total
+	fmt.Println(risk)
+	fmt.Println(product)
+	fmt.Printf("%dx%d, %d basins, parsed in %v, solved in %v\n",
+		heightmap.Rows(), heightmap.Cols(), len(sizes), parsed.Sub(start), time.Since(parsed))
+
+	return nil
 }
 
-func (solution AdventOfCodeDay9Solution) Part2() int {
-	data := solution.Data()
+// image walls are only used when -wall is given explicitly
+func runWatershed(in string, out string, options HeightmapOptions) error {
+	field, err := LoadField(in)
+	if err != nil {
+		return err
+	}
 
-	directions := [4][2]int{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+	wallSet := false
+	flag.Visit(func(f *flag.Flag) {
+		wallSet = wallSet || f.Name == "wall"
+	})
 
-	total := 1
+	if !wallSet {
+		options.IsWall = WatershedOptions().IsWall
+	}
 
-	largestBasins := [3]int{0, 0, 0}
-
-	for i := 0; i < len(data); i++ {
-		for j := 0; j < len(data[i]); j++ {
-			if data[i][j] != -1 {
-				val := data[i][j]
-
-				isLowPoint := true
-
-				for _, direction := range directions {
-					nextRow := i + direction[0]
-					nextCol := j + direction[1]
-
-					if nextRow < 0 || nextCol < 0 {
-						continue
-					}
-
-					if nextRow == len(data) || nextCol == len(data[0]) {
-						continue
-					}
-
-					if val >= data[nextRow][nextCol] {
-						isLowPoint = false
-						break
-					}
-				}
-
-				if isLowPoint {
-					queue := [][3]int{{i, j, val}}
-					count := 0
-
-					for len(queue) > 0 {
-						count += 1
-						row := queue[0][0]
-						col := queue[0][1]
-						val := queue[0][2]
-						queue = queue[1:]
-
-						for _, direction := range directions {
-							nextRow := row + direction[0]
-							nextCol := col + direction[1]
-
-							if nextRow < 0 || nextCol < 0 {
-								continue
-							}
-
-							if nextRow == len(data) || nextCol == len(data[0]) {
-								continue
-							}
-
-							nextVal := data[nextRow][nextCol]
-
-							if nextVal != 9 && nextVal != -1 && nextVal > val {
-								queue = append(queue, [3]int{nextRow, nextCol, nextVal})
-								data[nextRow][nextCol] = -1
-							}
-						}
-					}
-
-					if count > largestBasins[0] {
-						largestBasins[2] = largestBasins[1]
-						largestBasins[1] = largestBasins[0]
-						largestBasins[0] = count
-					} else if count > largestBasins[1] {
-						largestBasins[2] = largestBasins[1]
-						largestBasins[1] = count
-					} else if count > largestBasins[2] {
-						largestBasins[2] = count
-					}
-				}
-			}
-		}
+	segmentation := Watershed(field, options, DrainageOptions{})
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	for _, val := range largestBasins {
-		if val != 0 {
-			total *= val
-		}
+	if strings.ToLower(filepath.Ext(out)) == ".png" {
+		err = segmentation.WritePNG(f)
+	} else {
+		err = segmentation.WriteLabels(f)
 	}
 
-	return total
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d segments\n", len(segmentation.Segments))
+	return nil
 }
 
 func main() {
+	wallHeight := flag.Int("wall", 9, "cells at or above this height are basin walls")
+	connectivity := flag.Int("connectivity", 4, "neighbourhood used for low points and basins, 4 or 8")
+	wrap := flag.Bool("wrap", false, "treat the heightmap edges as wrapping around")
+	render := flag.String("render", "", "draw the basins, \"ansi\" to the terminal or \"png\" to -out")
+	out := flag.String("out", "basins.png", "file written by -render png")
+	scale := flag.Int("scale", 8, "pixels per cell for -render png")
+	segment := flag.String("segment", "", "watershed segmentation of a .png, .pgm or digit-row file, written to -segment-out")
+	segmentOut := flag.String("segment-out", "segments.txt", "labels of -segment, as text or as a .png image")
+	large := flag.String("large", "", "solve a large heightmap file with the compact striped solver")
+	stripe := flag.Int("stripe", 256, "rows per stripe for -large")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines labelling stripes for -large")
+	rain := flag.Float64("rain", 0, "simulate rainfall of this depth per cell and print the basin fill levels")
+	flag.Parse()
+
+	if *connectivity != 4 && *connectivity != 8 {
+		log.Fatalf("connectivity must be 4 or 8, got %d", *connectivity)
+	}
+	// a wrapped map has no edge to drain over, so the trapped water to compare against is unbounded
+	if *rain > 0 && *wrap {
+		log.Fatal("-rain does not support -wrap")
+	}
+	if *wallHeight < 1 {
+		log.Fatalf("wall height must be at least 1, got %d", *wallHeight)
+	}
+
+	options := HeightmapOptions{WallHeight: *wallHeight, Connectivity: Connectivity(*connectivity), Wrap: *wrap}
+
+	if *large != "" {
+		// the compact solver only knows 4 bounded neighbours and byte heights
+		if options.Connectivity != Connect4 || options.Wrap {
+			log.Fatal("-large supports neither -connectivity 8 nor -wrap")
+		}
+		if *wallHeight > 255 {
+			log.Fatalf("-large needs a wall height between 1 and 255, got %d", *wallHeight)
+		}
+
+		if err := runLarge(*large, CompactOptions{StripeRows: *stripe, Workers: *workers, WallHeight: byte(*wallHeight)}); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *segment != "" {
+		if err := runWatershed(*segment, *segmentOut, options); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	fileDataSource := AdventOfCodeFileDataSourceDay9{"test_data2"}
-	solution := AdventOfCodeDay9Solution{fileDataSource, nil}
+	solution := AdventOfCodeDay9Solution{fileDataSource, nil, options}
 	fmt.Println(solution.Part1())
 	fmt.Println(solution.Part2())
+
+	switch *render {
+	case "":
+	case "ansi":
+		fmt.Print(solution.Heightmap().Renderer().ANSI())
+	case "png":
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		if err := solution.Heightmap().Renderer().WritePNG(f, *scale); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("unknown -render %q, expected ansi or png", *render)
+	}
+
+	if *rain > 0 {
+		heightmap := solution.Heightmap()
+		trapped, _ := heightmap.TrappedWater()
+		fmt.Printf("trapped water: %d\n", trapped)
+
+		simulation := heightmap.NewWaterSimulation()
+		simulation.Rain(*rain)
+
+		for _, fill := range simulation.Fills() {
+			basin := simulation.Basins()[fill.Basin]
+			fmt.Printf("basin %d at %d,%d: level %.2f, volume %.2f, full %v, spills into %d\n",
+				fill.Basin, basin.LowPoint.Row, basin.LowPoint.Col, fill.Level, fill.Volume, fill.Full, fill.SpillsInto)
+		}
+
+		fmt.Printf("lost off the map: %.2f\n", simulation.Lost())
+		fmt.Printf("stranded on walls: %.2f\n", simulation.Stranded())
+	}
 }
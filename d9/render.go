@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"aocgrid"
+)
+
+type BasinRenderer struct {
+	heightmap *Heightmap
+	labels    *aocgrid.Grid[int]
+	lowPoints map[aocgrid.Point]bool
+	palette   []color.RGBA
+}
+
+func NewBasinRenderer(h *Heightmap, basins []Basin) *BasinRenderer {
+	labels := aocgrid.Map(h.cells, func(int) int { return labelUnassigned })
+	lowPoints := map[aocgrid.Point]bool{}
+	palette := make([]color.RGBA, len(basins))
+
+	for i, basin := range basins {
+		for _, p := range basin.Cells {
+			labels.Set(p.Row, p.Col, i)
+		}
+		lowPoints[basin.LowPoint] = true
+		palette[i] = basinColor(i)
+	}
+
+	return &BasinRenderer{h, labels, lowPoints, palette}
+}
+
+func (h *Heightmap) Renderer() *BasinRenderer {
+	return NewBasinRenderer(h, h.Basins())
+}
+
+// hues a golden angle apart so neighbouring basin indices never look alike
+func basinColor(i int) color.RGBA {
+	hue := math.Mod(float64(i)*137.508, 360)
+	return hsvToRGB(hue, 0.55, 0.95)
+}
+
+func hsvToRGB(hue, saturation, value float64) color.RGBA {
+	c := value * saturation
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	m := value - c
+
+	var r, g, b float64
+
+	switch {
+	case hue < 60:
+		r, g, b = c, x, 0
+	case hue < 120:
+		r, g, b = x, c, 0
+	case hue < 180:
+		r, g, b = 0, c, x
+	case hue < 240:
+		r, g, b = 0, x, c
+	case hue < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 255}
+}
+
+// walls are shades of grey by height, cells outside any basin are plain white,
+// basin cells get darker towards the low point
+func (r *BasinRenderer) cellColor(row, col int) color.RGBA {
+	val := r.heightmap.cells.At(row, col)
+
+	if r.heightmap.isWall(val) {
+		shade := uint8(40 + min(val, 9)*8)
+		return color.RGBA{shade, shade, shade, 255}
+	}
+
+	label := r.labels.At(row, col)
+	if label < 0 {
+		return color.RGBA{255, 255, 255, 255}
+	}
+
+	base := r.palette[label]
+	factor := 0.6 + 0.4*float64(min(val, 9))/9
+
+	return color.RGBA{uint8(float64(base.R) * factor), uint8(float64(base.G) * factor), uint8(float64(base.B) * factor), 255}
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// one character per cell on a 24-bit background colour, low points are shown as '*'
+func (r *BasinRenderer) ANSI() string {
+	var out strings.Builder
+
+	for row := 0; row < r.labels.Rows(); row++ {
+		for col := 0; col < r.labels.Cols(); col++ {
+			c := r.cellColor(row, col)
+			fmt.Fprintf(&out, "\x1b[48;2;%d;%d;%dm\x1b[38;2;0;0;0m", c.R, c.G, c.B)
+
+			if r.lowPoints[aocgrid.Point{Row: row, Col: col}] {
+				out.WriteString("\x1b[1m*\x1b[22m")
+			} else {
+				fmt.Fprint(&out, r.heightmap.cells.At(row, col))
+			}
+		}
+		out.WriteString("\x1b[0m\n")
+	}
+
+	return out.String()
+}
+
+// every cell becomes a scale x scale square, low points get a black dot in the middle,
+// or are all black when the squares are too small for a dot
+func (r *BasinRenderer) Image(scale int) *image.RGBA {
+	if scale < 1 {
+		scale = 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, r.labels.Cols()*scale, r.labels.Rows()*scale))
+
+	r.labels.Each(func(row, col, _ int) {
+		c := r.cellColor(row, col)
+		lowPoint := r.lowPoints[aocgrid.Point{Row: row, Col: col}]
+
+		for y := 0; y < scale; y++ {
+			for x := 0; x < scale; x++ {
+				if lowPoint && (scale < 3 || 3*y >= scale && 3*y < 2*scale && 3*x >= scale && 3*x < 2*scale) {
+					img.SetRGBA(col*scale+x, row*scale+y, color.RGBA{0, 0, 0, 255})
+				} else {
+					img.SetRGBA(col*scale+x, row*scale+y, c)
+				}
+			}
+		}
+	})
+
+	return img
+}
+
+func (r *BasinRenderer) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, r.Image(scale))
+}
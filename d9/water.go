@@ -0,0 +1,415 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"aocgrid"
+)
+
+const (
+	offMap   = -1
+	stranded = -2
+	noLake   = -1
+	epsilon  = 1e-9
+	noSpill  = math.MaxInt
+	noSource = -1
+)
+
+type lake struct {
+	basins []int
+	cells  []aocgrid.Point
+	water  float64
+}
+
+type Spill struct {
+	// basin the water left from, and the basin it went to, offMap or stranded
+	From   int
+	To     int
+	Volume float64
+}
+
+type BasinFill struct {
+	Basin  int
+	Level  float64
+	Volume float64
+	// the basin is filled up to its spill height, more water runs over into SpillsInto
+	Full       bool
+	SpillsInto int
+}
+
+// a fill-and-spill model on top of the drainage basins: water collects in the basin it lands in,
+// once a basin is filled up to its lowest rim cell the excess runs into the neighbouring basin
+// behind that rim, and two basins filled up to a shared rim merge into one lake
+type WaterSimulation struct {
+	heightmap *Heightmap
+	drainage  *Drainage
+	owner     *aocgrid.Grid[int]
+	lakes     []*lake
+	spills    []Spill
+	lost      float64
+	stranded  float64
+}
+
+func (h *Heightmap) NewWaterSimulation() *WaterSimulation {
+	drainage := h.Drain(DrainageOptions{Ties: TieFirstDirection, Plateaus: PlateauSpread})
+
+	owner := aocgrid.Map(drainage.Labels, func(label int) int {
+		if label < 0 {
+			return noLake
+		}
+		return label
+	})
+
+	lakes := make([]*lake, len(drainage.Basins))
+	for i, basin := range drainage.Basins {
+		lakes[i] = &lake{[]int{i}, append([]aocgrid.Point{}, basin.Cells...), 0}
+	}
+
+	return &WaterSimulation{h, drainage, owner, lakes, []Spill{}, 0, 0}
+}
+
+func (s *WaterSimulation) Basins() []Basin {
+	return s.drainage.Basins
+}
+
+// water that ran off the edge of the map
+func (s *WaterSimulation) Lost() float64 {
+	return s.lost
+}
+
+// water that landed on walls which lead neither into a lake nor off the map, only a wrapped map
+// without any basin has those
+func (s *WaterSimulation) Stranded() float64 {
+	return s.stranded
+}
+
+func (s *WaterSimulation) Spills() []Spill {
+	return s.spills
+}
+
+func (s *WaterSimulation) Pour(p aocgrid.Point, volume float64) {
+	s.addWater(p, volume)
+	s.settle()
+}
+
+// every cell of the map receives depth units of water
+func (s *WaterSimulation) Rain(depth float64) {
+	s.heightmap.cells.Each(func(row, col, _ int) {
+		s.addWater(aocgrid.Point{Row: row, Col: col}, depth)
+	})
+	s.settle()
+}
+
+// water landing on a wall runs down into the lowest lake next to it
+func (s *WaterSimulation) addWater(p aocgrid.Point, volume float64) {
+	id := s.owner.At(p.Row, p.Col)
+	if id == noLake {
+		id = s.runoff(p, noLake)
+	}
+
+	switch id {
+	case offMap:
+		s.lost += volume
+		s.spills = append(s.spills, Spill{noSource, offMap, volume})
+	case stranded:
+		s.stranded += volume
+		s.spills = append(s.spills, Spill{noSource, stranded, volume})
+	default:
+		s.lakes[id].water += volume
+	}
+}
+
+func (s *WaterSimulation) onEdge(p aocgrid.Point) bool {
+	if s.heightmap.options.Wrap {
+		return false
+	}
+	return p.Row == 0 || p.Col == 0 || p.Row == s.owner.Rows()-1 || p.Col == s.owner.Cols()-1
+}
+
+// where water running over a cell that belongs to no lake ends up, other than in lake from:
+// the lowest lake next to it, or across the walls to the lowest outlet when it has none
+func (s *WaterSimulation) runoff(p aocgrid.Point, from int) int {
+	if s.onEdge(p) {
+		return offMap
+	}
+
+	lowest := noSpill
+	dest := stranded
+
+	s.heightmap.neighbours(p.Row, p.Col, func(nextRow, nextCol, nextVal int) {
+		id := s.owner.At(nextRow, nextCol)
+		if id != noLake && id != from && nextVal < lowest {
+			lowest = nextVal
+			dest = id
+		}
+	})
+
+	if dest == stranded {
+		dest = s.runoffAcrossWalls(p, from)
+	}
+
+	return dest
+}
+
+// a priority flood over the walls around p, the first lake or map edge it reaches is where the
+// water goes; stranded when the walls only lead back into lake from
+func (s *WaterSimulation) runoffAcrossWalls(p aocgrid.Point, from int) int {
+	visited := map[aocgrid.Point]bool{p: true}
+	queue := &floodQueue{{p, s.heightmap.cells.At(p.Row, p.Col)}}
+
+	for queue.Len() > 0 {
+		cell := heap.Pop(queue).(floodCell)
+
+		if id := s.owner.At(cell.point.Row, cell.point.Col); id != noLake {
+			return id
+		}
+		if s.onEdge(cell.point) {
+			return offMap
+		}
+
+		s.heightmap.neighbours(cell.point.Row, cell.point.Col, func(nextRow, nextCol, nextVal int) {
+			next := aocgrid.Point{Row: nextRow, Col: nextCol}
+			id := s.owner.At(nextRow, nextCol)
+			if visited[next] || (id != noLake && id == from) {
+				return
+			}
+			visited[next] = true
+			heap.Push(queue, floodCell{next, max(cell.height, nextVal)})
+		})
+	}
+
+	return stranded
+}
+
+// the height at which the lake starts to overflow, where the water goes, and the cell it runs over
+func (s *WaterSimulation) spill(id int) (int, int, aocgrid.Point) {
+	height := noSpill
+	dest := offMap
+	via := aocgrid.Point{}
+
+	for _, p := range s.lakes[id].cells {
+		val := s.heightmap.cells.At(p.Row, p.Col)
+
+		if s.onEdge(p) && val < height {
+			height, dest, via = val, offMap, p
+		}
+
+		s.heightmap.neighbours(p.Row, p.Col, func(nextRow, nextCol, nextVal int) {
+			next := aocgrid.Point{Row: nextRow, Col: nextCol}
+			nextID := s.owner.At(nextRow, nextCol)
+			over := max(val, nextVal)
+
+			if nextID == id || over >= height {
+				return
+			}
+
+			if nextID == noLake {
+				nextID = s.runoff(next, id)
+			}
+			// walls that only lead back into this lake are no outlet
+			if nextID == stranded {
+				return
+			}
+
+			height, dest, via = over, nextID, next
+		})
+	}
+
+	return height, dest, via
+}
+
+func (s *WaterSimulation) capacity(id int, level float64) float64 {
+	capacity := 0.0
+	for _, p := range s.lakes[id].cells {
+		capacity += math.Max(0, level-float64(s.heightmap.cells.At(p.Row, p.Col)))
+	}
+	return capacity
+}
+
+// the water surface of the lake, the lowest cell height when it is empty
+func (s *WaterSimulation) level(id int) float64 {
+	heights := make([]float64, len(s.lakes[id].cells))
+	for i, p := range s.lakes[id].cells {
+		heights[i] = float64(s.heightmap.cells.At(p.Row, p.Col))
+	}
+	sort.Float64s(heights)
+
+	water := s.lakes[id].water
+	sum := 0.0
+	level := heights[0]
+
+	for k := 1; k <= len(heights); k++ {
+		sum += heights[k-1]
+		level = (water + sum) / float64(k)
+
+		if k == len(heights) || level <= heights[k] {
+			break
+		}
+	}
+
+	return level
+}
+
+// whether the lake stands at least at height, a lake that spills lower never gets there:
+// its water is still on the way out through that lower outlet, and is not settled yet
+func (s *WaterSimulation) fullTo(id int, height int) bool {
+	spillHeight, _, _ := s.spill(id)
+	return spillHeight >= height && s.lakes[id].water >= s.capacity(id, float64(height))-epsilon
+}
+
+func (s *WaterSimulation) merge(into, from int, via aocgrid.Point) {
+	target := s.lakes[into]
+	source := s.lakes[from]
+
+	if s.owner.At(via.Row, via.Col) == noLake {
+		target.cells = append(target.cells, via)
+		s.owner.Set(via.Row, via.Col, into)
+	}
+
+	for _, p := range source.cells {
+		s.owner.Set(p.Row, p.Col, into)
+	}
+
+	target.basins = append(target.basins, source.basins...)
+	target.cells = append(target.cells, source.cells...)
+	target.water += source.water
+	s.lakes[from] = nil
+}
+
+// moves overflowing water downhill until no lake holds more than it can
+func (s *WaterSimulation) settle() {
+	for changed := true; changed; {
+		changed = false
+
+		for id, l := range s.lakes {
+			if l == nil {
+				continue
+			}
+
+			height, dest, via := s.spill(id)
+			if height == noSpill {
+				continue
+			}
+
+			capacity := s.capacity(id, float64(height))
+			if l.water <= capacity+epsilon {
+				continue
+			}
+
+			excess := l.water - capacity
+
+			switch {
+			case dest == offMap:
+				l.water = capacity
+				s.lost += excess
+				s.spills = append(s.spills, Spill{l.basins[0], offMap, excess})
+			case s.fullTo(dest, height):
+				s.merge(id, dest, via)
+				changed = true
+			default:
+				l.water = capacity
+				s.lakes[dest].water += excess
+				s.spills = append(s.spills, Spill{l.basins[0], s.lakes[dest].basins[0], excess})
+				changed = true
+			}
+		}
+	}
+}
+
+func (s *WaterSimulation) Fills() []BasinFill {
+	fills := []BasinFill{}
+
+	for i, basin := range s.drainage.Basins {
+		id := s.owner.At(basin.LowPoint.Row, basin.LowPoint.Col)
+		level := s.level(id)
+
+		volume := 0.0
+		for _, p := range basin.Cells {
+			volume += math.Max(0, level-float64(s.heightmap.cells.At(p.Row, p.Col)))
+		}
+
+		fill := BasinFill{Basin: i, Level: level, Volume: volume, SpillsInto: offMap}
+
+		height, dest, _ := s.spill(id)
+		if height != noSpill && s.lakes[id].water >= s.capacity(id, float64(height))-epsilon {
+			fill.Full = true
+			if dest != offMap {
+				fill.SpillsInto = s.lakes[dest].basins[0]
+			}
+		}
+
+		fills = append(fills, fill)
+	}
+
+	return fills
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+type floodCell struct {
+	point  aocgrid.Point
+	height int
+}
+
+type floodQueue []floodCell
+
+func (q floodQueue) Len() int            { return len(q) }
+func (q floodQueue) Less(i, j int) bool  { return q[i].height < q[j].height }
+func (q floodQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *floodQueue) Push(x interface{}) { *q = append(*q, x.(floodCell)) }
+func (q *floodQueue) Pop() interface{} {
+	old := *q
+	cell := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return cell
+}
+
+// the 2D trapping rain water problem: how much water stays on the terrain after unlimited rain,
+// flooding inwards from the map edge; a wrapped map has no edge and holds any amount of rain,
+// so Wrap is ignored here
+func (h *Heightmap) TrappedWater() (int, *aocgrid.Grid[int]) {
+	rows, cols := h.cells.Rows(), h.cells.Cols()
+	depths := aocgrid.New[int](rows, cols)
+	visited := aocgrid.New[bool](rows, cols)
+	queue := &floodQueue{}
+
+	directions := aocgrid.Directions4
+	if h.options.Connectivity == Connect8 {
+		directions = aocgrid.Directions8
+	}
+
+	h.cells.Each(func(row, col, val int) {
+		if row == 0 || col == 0 || row == rows-1 || col == cols-1 {
+			heap.Push(queue, floodCell{aocgrid.Point{Row: row, Col: col}, val})
+			visited.Set(row, col, true)
+		}
+	})
+
+	total := 0
+
+	for queue.Len() > 0 {
+		cell := heap.Pop(queue).(floodCell)
+
+		h.cells.Neighbours(cell.point.Row, cell.point.Col, directions, func(nextRow, nextCol, nextVal int) {
+			if visited.At(nextRow, nextCol) {
+				return
+			}
+			visited.Set(nextRow, nextCol, true)
+
+			level := max(cell.height, nextVal)
+			depths.Set(nextRow, nextCol, level-nextVal)
+			total += level - nextVal
+
+			heap.Push(queue, floodCell{aocgrid.Point{Row: nextRow, Col: nextCol}, level})
+		})
+	}
+
+	return total, depths
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"aocgrid"
+)
+
+func heightmapFromLines(t *testing.T, lines []string, options HeightmapOptions) *Heightmap {
+	cells, err := aocgrid.ParseDigits(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHeightmapWithOptions(cells, options)
+}
+
+// after enough rain every lake is filled up to its rim, which is exactly the water the
+// priority flood finds
+func assertSaturatedRainHoldsTrappedWater(t *testing.T, h *Heightmap) {
+	t.Helper()
+
+	rain := 1000.0
+	simulation := h.NewWaterSimulation()
+	simulation.Rain(rain)
+
+	held := rain*float64(h.cells.Len()) - simulation.Lost()
+	trapped, _ := h.TrappedWater()
+
+	if math.Abs(held-float64(trapped)) > 1e-6 {
+		t.Fatalf("rain holds %v units, trapped water is %d on\n%s", held, trapped, h.cells)
+	}
+}
+
+func TestSaturatedRainHoldsTrappedWater(t *testing.T) {
+	options := HeightmapOptions{WallHeight: 100, Connectivity: Connect4}
+
+	assertSaturatedRainHoldsTrappedWater(t, heightmapFromLines(t, []string{"01763", "48423", "39219", "74204", "70222"}, options))
+
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		rows, cols := 3+r.Intn(4), 3+r.Intn(4)
+		cells := aocgrid.New[int](rows, cols)
+		cells.Each(func(row, col, _ int) {
+			cells.Set(row, col, r.Intn(10))
+		})
+
+		assertSaturatedRainHoldsTrappedWater(t, NewHeightmapWithOptions(cells, options))
+	}
+
+	// walls at the top height are never pits, so the water landing between them has to reach a lake
+	for i := 0; i < 2000; i++ {
+		rows, cols := 3+r.Intn(5), 3+r.Intn(5)
+		cells := aocgrid.New[int](rows, cols)
+		cells.Each(func(row, col, _ int) {
+			cells.Set(row, col, []int{0, 3, 9, 9, 9}[r.Intn(5)])
+		})
+
+		assertSaturatedRainHoldsTrappedWater(t, NewHeightmap(cells))
+	}
+}
+
+func TestWaterOnWallsRunsAcrossThem(t *testing.T) {
+	options := HeightmapOptions{WallHeight: 5, Connectivity: Connect4}
+	h := heightmapFromLines(t, []string{"99999", "96669", "96619", "99999"}, options)
+
+	simulation := h.NewWaterSimulation()
+	simulation.Pour(aocgrid.Point{Row: 1, Col: 1}, 1)
+
+	if simulation.Lost() != 0 || simulation.Stranded() != 0 {
+		t.Fatalf("lost %v, stranded %v, want the water in the lake", simulation.Lost(), simulation.Stranded())
+	}
+	if fill := simulation.Fills()[0]; fill.Volume != 1 {
+		t.Errorf("lake holds %v, want 1", fill.Volume)
+	}
+
+	wrapped := heightmapFromLines(t, []string{"99", "99"}, HeightmapOptions{Wrap: true})
+	simulation = wrapped.NewWaterSimulation()
+	simulation.Rain(1)
+
+	if simulation.Lost() != 0 || simulation.Stranded() != 4 {
+		t.Errorf("wrapped walls: lost %v, stranded %v, want all 4 units stranded", simulation.Lost(), simulation.Stranded())
+	}
+}
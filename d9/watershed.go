@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"aocgrid"
+)
+
+// any 2D integer field, cells drain by steepest descent and every sink becomes one segment
+type Segmentation struct {
+	heightmap *Heightmap
+	// segment index per cell, negative for walls and unassigned cells
+	Labels   *aocgrid.Grid[int]
+	Segments []Basin
+}
+
+// images have no natural basin walls, every pixel belongs to some segment
+func WatershedOptions() HeightmapOptions {
+	return HeightmapOptions{IsWall: func(int) bool { return false }, Connectivity: Connect4}
+}
+
+func Watershed(field *aocgrid.Grid[int], options HeightmapOptions, drainageOptions DrainageOptions) *Segmentation {
+	heightmap := NewHeightmapWithOptions(field, options)
+	drainage := heightmap.Drain(drainageOptions)
+	return &Segmentation{heightmap, drainage.Labels, drainage.Basins}
+}
+
+// one row of space separated segment labels per line
+func (s *Segmentation) WriteLabels(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	for row := 0; row < s.Labels.Rows(); row++ {
+		for col := 0; col < s.Labels.Cols(); col++ {
+			if col > 0 {
+				out.WriteByte(' ')
+			}
+			out.WriteString(strconv.Itoa(s.Labels.At(row, col)))
+		}
+		out.WriteByte('\n')
+	}
+
+	return out.Flush()
+}
+
+func (s *Segmentation) WritePNG(w io.Writer) error {
+	return NewBasinRenderer(s.heightmap, s.Segments).WritePNG(w, 1)
+}
+
+// .png and .pgm files are read as grayscale images, anything else as rows of digits
+func LoadField(path string) (*aocgrid.Grid[int], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return ReadPNGField(f)
+	case ".pgm":
+		return ReadPGMField(f)
+	}
+
+	lines, err := readLines(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return aocgrid.ParseDigits(lines)
+}
+
+func ReadPNGField(r io.Reader) (*aocgrid.Grid[int], error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	field := aocgrid.New[int](bounds.Dy(), bounds.Dx())
+
+	// 16-bit images keep all of their precision, like pgm files with a maxVal over 255
+	wide := false
+	switch img.ColorModel() {
+	case color.Gray16Model, color.RGBA64Model, color.NRGBA64Model:
+		wide = true
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray := color.Gray16Model.Convert(img.At(x, y)).(color.Gray16)
+
+			if wide {
+				field.Set(y-bounds.Min.Y, x-bounds.Min.X, int(gray.Y))
+			} else {
+				field.Set(y-bounds.Min.Y, x-bounds.Min.X, int(gray.Y>>8))
+			}
+		}
+	}
+
+	return field, nil
+}
+
+// plain (P2) and binary (P5) netpbm graymaps
+func ReadPGMField(r io.Reader) (*aocgrid.Grid[int], error) {
+	in := bufio.NewReader(r)
+
+	header := make([]int, 3)
+	magic, err := pgmToken(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if magic != "P2" && magic != "P5" {
+		return nil, fmt.Errorf("pgm: unsupported format %q", magic)
+	}
+
+	for i := range header {
+		token, err := pgmToken(in)
+		if err != nil {
+			return nil, err
+		}
+
+		header[i], err = strconv.Atoi(token)
+		if err != nil || header[i] <= 0 {
+			return nil, fmt.Errorf("pgm: invalid header value %q", token)
+		}
+	}
+
+	width, height, maxVal := header[0], header[1], header[2]
+	if maxVal > 65535 {
+		return nil, fmt.Errorf("pgm: max value %d out of range", maxVal)
+	}
+
+	field := aocgrid.New[int](height, width)
+
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			val, err := pgmSample(in, magic, maxVal)
+			if err != nil {
+				return nil, fmt.Errorf("pgm: pixel %d,%d: %w", row, col, err)
+			}
+			field.Set(row, col, val)
+		}
+	}
+
+	return field, nil
+}
+
+// the next whitespace separated header token, skipping # comments
+func pgmToken(in *bufio.Reader) (string, error) {
+	var token strings.Builder
+
+	for {
+		b, err := in.ReadByte()
+		if err == io.EOF && token.Len() > 0 {
+			return token.String(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case b == '#' && token.Len() == 0:
+			if _, err := in.ReadString('\n'); err != nil {
+				return "", err
+			}
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+			if token.Len() > 0 {
+				return token.String(), nil
+			}
+		default:
+			token.WriteByte(b)
+		}
+	}
+}
+
+func pgmSample(in *bufio.Reader, magic string, maxVal int) (int, error) {
+	if magic == "P2" {
+		token, err := pgmToken(in)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(token)
+	}
+
+	hi, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if maxVal < 256 {
+		return int(hi), nil
+	}
+
+	lo, err := in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(hi)<<8 | int(lo), nil
+}
@@ -0,0 +1,3 @@
+module aocgrid
+
+go 1.18
@@ -0,0 +1,178 @@
+package aocgrid
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Point struct {
+	Row int
+	Col int
+}
+
+func (p Point) Add(q Point) Point {
+	return Point{p.Row + q.Row, p.Col + q.Col}
+}
+
+var Directions4 = []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}}
+var Directions8 = []Point{{0, 1}, {1, 0}, {0, -1}, {-1, 0}, {1, 1}, {-1, -1}, {1, -1}, {-1, 1}}
+
+// cells are stored row by row in a single slice
+type Grid[T any] struct {
+	rows  int
+	cols  int
+	cells []T
+}
+
+func New[T any](rows, cols int) *Grid[T] {
+	return &Grid[T]{rows, cols, make([]T, rows*cols)}
+}
+
+func FromRows[T any](rows [][]T) (*Grid[T], error) {
+	if len(rows) == 0 {
+		return New[T](0, 0), nil
+	}
+
+	g := New[T](len(rows), len(rows[0]))
+
+	for i, row := range rows {
+		if len(row) != g.cols {
+			return nil, fmt.Errorf("row %d has %d columns, expected %d", i+1, len(row), g.cols)
+		}
+		copy(g.cells[i*g.cols:], row)
+	}
+
+	return g, nil
+}
+
+func ParseDigits(lines []string) (*Grid[int], error) {
+	rows := [][]int{}
+
+	for i, line := range lines {
+		row := make([]int, 0, len(line))
+
+		for j, ch := range line {
+			if ch < '0' || ch > '9' {
+				return nil, fmt.Errorf("line %d, column %d: %q is not a digit", i+1, j+1, ch)
+			}
+			row = append(row, int(ch-'0'))
+		}
+
+		rows = append(rows, row)
+	}
+
+	return FromRows(rows)
+}
+
+func (g *Grid[T]) Rows() int {
+	return g.rows
+}
+
+func (g *Grid[T]) Cols() int {
+	return g.cols
+}
+
+func (g *Grid[T]) Len() int {
+	return len(g.cells)
+}
+
+func (g *Grid[T]) InBounds(row, col int) bool {
+	return row >= 0 && row < g.rows && col >= 0 && col < g.cols
+}
+
+func (g *Grid[T]) Get(row, col int) (T, bool) {
+	if !g.InBounds(row, col) {
+		var zero T
+		return zero, false
+	}
+	return g.cells[row*g.cols+col], true
+}
+
+// At and Set panic on out of range coordinates, use Get or InBounds first when unsure
+func (g *Grid[T]) At(row, col int) T {
+	if !g.InBounds(row, col) {
+		panic(fmt.Sprintf("grid index %d,%d out of range %dx%d", row, col, g.rows, g.cols))
+	}
+	return g.cells[row*g.cols+col]
+}
+
+func (g *Grid[T]) Set(row, col int, val T) {
+	if !g.InBounds(row, col) {
+		panic(fmt.Sprintf("grid index %d,%d out of range %dx%d", row, col, g.rows, g.cols))
+	}
+	g.cells[row*g.cols+col] = val
+}
+
+func (g *Grid[T]) Each(visit func(row, col int, val T)) {
+	for i, val := range g.cells {
+		visit(i/g.cols, i%g.cols, val)
+	}
+}
+
+// calls visit for every in-bounds cell reached by one of the directions
+func (g *Grid[T]) Neighbours(row, col int, directions []Point, visit func(row, col int, val T)) {
+	for _, dir := range directions {
+		nextRow := row + dir.Row
+		nextCol := col + dir.Col
+
+		if !g.InBounds(nextRow, nextCol) {
+			continue
+		}
+
+		visit(nextRow, nextCol, g.cells[nextRow*g.cols+nextCol])
+	}
+}
+
+// like Neighbours, but coordinates past an edge continue on the opposite edge
+func (g *Grid[T]) NeighboursWrap(row, col int, directions []Point, visit func(row, col int, val T)) {
+	for _, dir := range directions {
+		nextRow := ((row+dir.Row)%g.rows + g.rows) % g.rows
+		nextCol := ((col+dir.Col)%g.cols + g.cols) % g.cols
+
+		visit(nextRow, nextCol, g.cells[nextRow*g.cols+nextCol])
+	}
+}
+
+func (g *Grid[T]) Neighbours4(row, col int, visit func(row, col int, val T)) {
+	g.Neighbours(row, col, Directions4, visit)
+}
+
+func (g *Grid[T]) Neighbours8(row, col int, visit func(row, col int, val T)) {
+	g.Neighbours(row, col, Directions8, visit)
+}
+
+func (g *Grid[T]) Copy() *Grid[T] {
+	cells := make([]T, len(g.cells))
+	copy(cells, g.cells)
+	return &Grid[T]{g.rows, g.cols, cells}
+}
+
+func Map[T, U any](g *Grid[T], f func(T) U) *Grid[U] {
+	mapped := New[U](g.rows, g.cols)
+	for i, val := range g.cells {
+		mapped.cells[i] = f(val)
+	}
+	return mapped
+}
+
+func Fold[T, A any](g *Grid[T], initial A, f func(acc A, row, col int, val T) A) A {
+	acc := initial
+	for i, val := range g.cells {
+		acc = f(acc, i/g.cols, i%g.cols, val)
+	}
+	return acc
+}
+
+func (g *Grid[T]) String() string {
+	lines := make([]string, 0, g.rows)
+
+	for i := 0; i < g.rows; i++ {
+		var line strings.Builder
+		for _, val := range g.cells[i*g.cols : (i+1)*g.cols] {
+			fmt.Fprint(&line, val)
+		}
+		lines = append(lines, line.String())
+	}
+
+	return strings.Join(lines, "\n")
+}